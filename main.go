@@ -22,13 +22,26 @@ func main() {
 	}
 
 	// Init clients
-	k8sClient, err := kubernetes.NewClient(cfg.KubeconfigPath)
+	k8sClient, err := kubernetes.NewClient(cfg.KubeconfigPath, kubernetes.Scope{
+		Namespaces:        cfg.Namespaces,
+		ExcludeNamespaces: cfg.ExcludeNamespaces,
+		NamespaceSelector: cfg.NamespaceSelector,
+		LabelSelector:     cfg.LabelSelector,
+	})
 	if err != nil {
 		slogs.Logr.Fatal("creating Kubernetes client", "error", err)
 	}
 	slogs.Logr.Info("Successfully connected to Kubernetes cluster")
 
-	cfClient, err := cloudflare.NewClient(cfg.CloudflareAPIToken)
+	var cfOpts []cloudflare.Option
+	if cfg.Registry == config.RegistryTXT {
+		cfOpts = append(cfOpts, cloudflare.WithTXTRegistry())
+	}
+	if cfg.DryRun {
+		slogs.Logr.Info("Running in dry run mode, DNS records will not be changed")
+		cfOpts = append(cfOpts, cloudflare.WithDryRun())
+	}
+	cfClient, err := cloudflare.NewClient(cfg.CloudflareAPIToken, cfOpts...)
 	if err != nil {
 		slogs.Logr.Fatal("creating Cloudflare client", "error", err)
 	}
@@ -3,107 +3,165 @@ package cloudflare
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/chia-network/go-modules/pkg/slogs"
 	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
 )
 
-// Client wraps the official Cloudflare Go client
+// Client wraps the official Cloudflare Go client and implements provider.Provider
 type Client struct {
-	api *cloudflare.API
+	api       *cloudflare.API
+	zoneCache *zoneCache
+	registry  registry
+	dryRun    bool
+}
+
+var _ provider.Provider = (*Client)(nil)
+
+// Option configures optional settings on a Client
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	baseURL      string
+	zoneCacheTTL time.Duration
+	txtRegistry  bool
+	dryRun       bool
+}
+
+// WithBaseURL sets the base URL of the Cloudflare API, useful for pointing the client at a fake API server
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithZoneCacheTTL sets how long the list of zones the API token can access is cached for
+func WithZoneCacheTTL(ttl time.Duration) Option {
+	return func(o *clientOptions) {
+		o.zoneCacheTTL = ttl
+	}
+}
+
+// WithTXTRegistry stores record ownership in companion TXT records instead of in record comments
+func WithTXTRegistry() Option {
+	return func(o *clientOptions) {
+		o.txtRegistry = true
+	}
+}
+
+// WithDryRun makes the client log the records it would create, update, or delete instead of changing them
+// Records are still read from the API, so ownership conflicts are reported as usual
+func WithDryRun() Option {
+	return func(o *clientOptions) {
+		o.dryRun = true
+	}
 }
 
 // NewClient creates a new Cloudflare API client
-func NewClient(apiToken string) (*Client, error) {
-	api, err := cloudflare.NewWithAPIToken(apiToken)
+func NewClient(apiToken string, opts ...Option) (*Client, error) {
+	options := &clientOptions{
+		zoneCacheTTL: defaultZoneCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var apiOpts []cloudflare.Option
+	if options.baseURL != "" {
+		apiOpts = append(apiOpts, cloudflare.BaseURL(options.baseURL))
+	}
+
+	api, err := cloudflare.NewWithAPIToken(apiToken, apiOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating Cloudflare client: %w", err)
 	}
 
-	return &Client{
+	client := &Client{
 		api: api,
-	}, nil
+		zoneCache: &zoneCache{
+			ttl: options.zoneCacheTTL,
+		},
+		registry: commentRegistry{},
+		dryRun:   options.dryRun,
+	}
+	if options.txtRegistry {
+		client.registry = &txtRegistry{client: client}
+	}
+	return client, nil
 }
 
-// RecordType represents a DNS record type
-type RecordType string
+// FindRecord finds a DNS record by zone, name, and type
+func (c *Client) FindRecord(ctx context.Context, zoneID, recordName string, recordType provider.RecordType) (*provider.Record, error) {
+	records, err := c.FindRecords(ctx, zoneID, recordName, recordType)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	// Return the first matching record
+	return &records[0], nil
+}
 
-const (
-	// RecordTypeA represents the identifier for an A record
-	RecordTypeA RecordType = "A"
-	// RecordTypeAAAA represents the identifier for an AAAA record
-	RecordTypeAAAA RecordType = "AAAA"
-)
+// ListRecords lists all DNS records in a zone
+func (c *Client) ListRecords(ctx context.Context, zoneID string) ([]provider.Record, error) {
+	cfRecords, _, err := c.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		c.checkZoneNotFound(err)
+		return nil, fmt.Errorf("error listing DNS records: %w", err)
+	}
 
-// DNSRecord represents a Cloudflare DNS record
-type DNSRecord struct {
-	ID      string
-	Type    RecordType
-	Name    string
-	Content string
-	TTL     int // 1 = auto, or seconds
-	Proxied bool
-	comment string // Processed internally by package
-
-	// Fields that go in the record's comments as metadata:
-	OwnerID string // Owner/comment field for tracking record ownership
+	return c.registry.records(ctx, zoneID, cfRecords, true)
 }
 
-// GetZoneIDByName finds a zone ID by its name
-func (c *Client) GetZoneIDByName(zoneName string) (string, error) {
-	zoneID, err := c.api.ZoneIDByName(zoneName)
+// FindRecords finds every DNS record in a zone with the given name and type
+func (c *Client) FindRecords(ctx context.Context, zoneID, recordName string, recordType provider.RecordType) ([]provider.Record, error) {
+	cfRecords, err := c.findRecords(ctx, zoneID, recordName, recordType)
 	if err != nil {
-		return "", fmt.Errorf("error getting zone ID for %s: %w", zoneName, err)
+		return nil, err
 	}
-	return zoneID, nil
+
+	return c.registry.records(ctx, zoneID, cfRecords, false)
 }
 
-// FindRecord finds a DNS record by zone, name, and type
-func (c *Client) FindRecord(ctx context.Context, zoneID, recordName string, recordType RecordType) (*DNSRecord, error) {
+// findRecords finds the Cloudflare representation of every DNS record with the given zone, name, and type
+func (c *Client) findRecords(ctx context.Context, zoneID, recordName string, recordType provider.RecordType) ([]cloudflare.DNSRecord, error) {
 	records, _, err := c.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
 		Name: recordName,
 		Type: string(recordType),
 	})
 	if err != nil {
+		c.checkZoneNotFound(err)
 		return nil, fmt.Errorf("error listing DNS records: %w", err)
 	}
-
-	if len(records) == 0 {
-		return nil, nil // Record not found
-	}
-
-	// Return the first matching record
-	record := records[0]
-	return &DNSRecord{
-		ID:      record.ID,
-		Type:    RecordType(record.Type),
-		Name:    record.Name,
-		Content: record.Content,
-		TTL:     record.TTL,
-		Proxied: record.Proxied != nil && *record.Proxied,
-		comment: record.Comment,
-		OwnerID: extractOwnerFromComment(record.Comment),
-	}, nil
+	return records, nil
 }
 
 // createRecord creates a new DNS record
 // If this is made to be a public function in the future, it should check for ownership in the same way that UpsertRecord does
-func (c *Client) createRecord(ctx context.Context, zoneID string, record DNSRecord) (*DNSRecord, error) {
+func (c *Client) createRecord(ctx context.Context, zoneID string, record provider.Record) (*provider.Record, error) {
 	cfRecord := cloudflare.CreateDNSRecordParams{
 		Type:    string(record.Type),
 		Name:    record.Name,
 		Content: record.Content,
 		TTL:     record.TTL,
-		Comment: formatCommentMetadata(record.OwnerID),
+	}
+	if comment := c.registry.comment(record); comment != nil {
+		cfRecord.Comment = *comment
 	}
 
 	proxied := record.Proxied
 	cfRecord.Proxied = &proxied
 
+	if c.dryRun {
+		logDryRunCreate(cfRecord)
+		return &record, nil
+	}
+
 	created, err := c.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), cfRecord)
 	if err != nil {
+		c.checkZoneNotFound(err)
 		return nil, err
 	}
 
@@ -115,24 +173,21 @@ func (c *Client) createRecord(ctx context.Context, zoneID string, record DNSReco
 		"proxied", record.Proxied,
 		"owner", record.OwnerID)
 
-	return &DNSRecord{
-		ID:      created.ID,
-		Type:    RecordType(created.Type),
-		Name:    created.Name,
-		Content: created.Content,
-		TTL:     created.TTL,
-		Proxied: created.Proxied != nil && *created.Proxied,
-		OwnerID: extractOwnerFromComment(created.Comment),
-	}, nil
+	result := toRecord(created)
+	result.OwnerID, result.Source = record.OwnerID, record.Source
+	return &result, nil
 }
 
-// updateRecord updates an existing DNS record
+// updateRecord updates an existing DNS record, returning false if it was already up to date
 // If this is made to be a public function in the future, it should check for ownership in the same way that UpsertRecord does
-func (c *Client) updateRecord(ctx context.Context, zoneID string, currentRecord DNSRecord, record DNSRecord) (*DNSRecord, error) {
+func (c *Client) updateRecord(ctx context.Context, zoneID string, currentRecord cloudflare.DNSRecord, record provider.Record) (*provider.Record, bool, error) {
 	// Check if all record fields are already up to date before updating
 	record.ID = currentRecord.ID
-	if currentRecord == record {
-		return &record, nil
+	comment := c.registry.comment(record)
+	current := toRecord(currentRecord)
+	current.OwnerID, current.Source = record.OwnerID, record.Source // Ownership was already checked by the caller, and any comment is compared below
+	if current == record && (comment == nil || currentRecord.Comment == *comment) {
+		return &record, false, nil
 	}
 
 	// Assemble update record params and make the request
@@ -144,13 +199,18 @@ func (c *Client) updateRecord(ctx context.Context, zoneID string, currentRecord
 		Content: record.Content,
 		TTL:     record.TTL,
 		Proxied: &proxied,
+		Comment: comment,
+	}
+
+	if c.dryRun {
+		logDryRunUpdate(currentRecord, cfRecord)
+		return &record, true, nil
 	}
-	comment := formatCommentMetadata(record.OwnerID)
-	cfRecord.Comment = &comment
 
 	updated, err := c.api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), cfRecord)
 	if err != nil {
-		return nil, err
+		c.checkZoneNotFound(err)
+		return nil, false, err
 	}
 
 	slogs.Logr.Info("Successfully updated record",
@@ -161,129 +221,177 @@ func (c *Client) updateRecord(ctx context.Context, zoneID string, currentRecord
 		"proxied", record.Proxied,
 		"owner", record.OwnerID)
 
-	return &DNSRecord{
-		ID:      updated.ID,
-		Type:    RecordType(updated.Type),
-		Name:    updated.Name,
-		Content: updated.Content,
-		TTL:     updated.TTL,
-		Proxied: updated.Proxied != nil && *updated.Proxied,
-		OwnerID: extractOwnerFromComment(updated.Comment),
-	}, nil
+	result := toRecord(updated)
+	result.OwnerID, result.Source = record.OwnerID, record.Source
+	return &result, true, nil
 }
 
-// DeleteRecord deletes a DNS record
-func (c *Client) DeleteRecord(ctx context.Context, zoneID string, record DNSRecord) error {
-	existing, err := c.FindRecord(ctx, zoneID, record.Name, record.Type)
-	if err != nil {
-		return fmt.Errorf("error finding record: %w", err)
+// deleteRecord deletes an existing DNS record by its ID
+// If this is made to be a public function in the future, it should check for ownership in the same way that DeleteRecord does
+func (c *Client) deleteRecord(ctx context.Context, zoneID string, record cloudflare.DNSRecord) error {
+	if c.dryRun {
+		logDryRunDelete(record)
+		return nil
 	}
 
-	if existing != nil {
-		if hasOwnerConflict(*existing, record) {
-			return fmt.Errorf("record ownership conflict: existing owner '%s' does not match expected owner '%s'", existing.OwnerID, record.OwnerID)
-		}
-
-		err = c.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), existing.ID)
-		if err != nil {
-			return fmt.Errorf("error deleting DNS record: %w", err)
-		}
+	err := c.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), record.ID)
+	if err != nil {
+		c.checkZoneNotFound(err)
+		return fmt.Errorf("error deleting DNS record: %w", err)
 	}
 
+	slogs.Logr.Info("Successfully deleted record",
+		"type", record.Type,
+		"name", record.Name,
+		"ip", record.Content)
+
 	return nil
 }
 
-// UpsertRecord creates or updates a DNS record with ownership checking
-// If the record exists and has a different owner, it returns an error
-// If the record exists with no owner, it updates the record with the new owner
-func (c *Client) UpsertRecord(ctx context.Context, zoneID string, record DNSRecord) (*DNSRecord, error) {
-	existing, err := c.FindRecord(ctx, zoneID, record.Name, record.Type)
+// DeleteRecord deletes every DNS record with the record's name and type
+// If any of the existing records has a different owner, it returns an error without deleting anything
+func (c *Client) DeleteRecord(ctx context.Context, zoneID string, record provider.Record) ([]provider.Record, error) {
+	existing, err := c.findRecords(ctx, zoneID, record.Name, record.Type)
 	if err != nil {
 		return nil, fmt.Errorf("error finding record: %w", err)
 	}
 
-	if existing != nil {
-		if hasOwnerConflict(*existing, record) {
-			return nil, fmt.Errorf("record ownership conflict: existing owner '%s' does not match expected owner '%s'", existing.OwnerID, record.OwnerID)
-		}
-
-		// Format record comment metadata
-		record.comment = formatCommentMetadata(record.OwnerID)
+	// The registry entry is looked up even with no records left, so an entry left behind by an earlier failure is released
+	current, entry, err := c.registry.lookup(ctx, zoneID, record, existing)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOwnership(current, entry, record); err != nil {
+		return nil, err
+	}
 
-		// Update existing record
-		return c.updateRecord(ctx, zoneID, *existing, record)
+	for _, cfRecord := range existing {
+		if err := c.deleteRecord(ctx, zoneID, cfRecord); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create new record with formatted comment metadata
-	record.comment = formatCommentMetadata(record.OwnerID)
-	return c.createRecord(ctx, zoneID, record)
+	if err := c.registry.release(ctx, zoneID, record, entry); err != nil {
+		return nil, err
+	}
+	return current, nil
 }
 
-// ParseTTL parses TTL string to int (1 for auto, or seconds)
-func ParseTTL(ttlStr string) (int, error) {
-	if ttlStr == "" || ttlStr == "auto" {
-		return 1, nil // Auto TTL
+// UpsertRecord creates or updates a DNS record with ownership checking
+// If the record exists and has a different owner, it returns an error
+// If the record exists with no owner, it updates the record with the new owner
+func (c *Client) UpsertRecord(ctx context.Context, zoneID string, record provider.Record) (*provider.Record, error) {
+	existing, err := c.findRecords(ctx, zoneID, record.Name, record.Type)
+	if err != nil {
+		return nil, fmt.Errorf("error finding record: %w", err)
 	}
 
-	ttl, err := strconv.Atoi(ttlStr)
+	current, entry, err := c.registry.lookup(ctx, zoneID, record, existing[:min(len(existing), 1)])
 	if err != nil {
-		return 0, fmt.Errorf("invalid TTL: %s", ttlStr)
+		return nil, err
+	}
+	if err := checkOwnership(current, entry, record); err != nil {
+		return nil, err
 	}
 
-	if ttl < 1 {
-		return 1, nil // Default to 1 second if TTL is invalid
+	var result *provider.Record
+	if len(existing) > 0 {
+		// Update existing record
+		result, _, err = c.updateRecord(ctx, zoneID, existing[0], record)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Create new record
+		result, err = c.createRecord(ctx, zoneID, record)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return ttl, nil
+	if err := c.registry.claim(ctx, zoneID, record, entry); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// ParseProxied parses proxied string to bool
-func ParseProxied(proxiedStr string) (bool, error) {
-	if proxiedStr == "" {
-		return false, nil
+// UpsertRecordSet makes the DNS records with a name and type match the given records, with ownership checking
+// Missing records are created, existing records are updated in place where possible, and stale records are deleted
+// If any existing record in the set has a different owner, it returns an error without changing anything
+func (c *Client) UpsertRecordSet(ctx context.Context, zoneID string, records []provider.Record) (provider.RecordSetChanges, error) {
+	if len(records) == 0 {
+		return provider.RecordSetChanges{}, fmt.Errorf("record set must contain at least one record")
 	}
 
-	proxied, err := strconv.ParseBool(proxiedStr)
+	existing, err := c.findRecords(ctx, zoneID, records[0].Name, records[0].Type)
 	if err != nil {
-		return false, fmt.Errorf("invalid proxied value: %s (must be 'true' or 'false')", proxiedStr)
+		return provider.RecordSetChanges{}, fmt.Errorf("error finding records: %w", err)
 	}
 
-	return proxied, nil
-}
-
-// formatCommentMetadata formats record metadata into a comment string
-// Format: "record-owner-id=$ownerID"
-func formatCommentMetadata(ownerID string) string {
-	if ownerID == "" {
-		return ""
+	current, entry, err := c.registry.lookup(ctx, zoneID, records[0], existing)
+	if err != nil {
+		return provider.RecordSetChanges{}, err
+	}
+	if err := checkOwnership(current, entry, records[0]); err != nil {
+		return provider.RecordSetChanges{}, err
 	}
-	return fmt.Sprintf("record-owner-id=%s", ownerID)
-}
 
-// extractOwnerFromComment extracts the owner ID from a comment string
-func extractOwnerFromComment(comment string) string {
-	if comment == "" {
-		return ""
+	existingByID := make(map[string]cloudflare.DNSRecord, len(existing))
+	for _, cfRecord := range existing {
+		existingByID[cfRecord.ID] = cfRecord
 	}
 
-	// Parse key-value pairs separated by spaces
-	// Format: "key=value key2=value2 key3=value3"
-	pairs := strings.Fields(comment)
-	for _, pair := range pairs {
-		parts := strings.SplitN(pair, "=", 2)
-		if len(parts) == 2 && parts[0] == "record-owner-id" {
-			return parts[1]
+	// Update and create before deleting so the name keeps resolving throughout
+	changes := provider.DiffRecordSet(current, records)
+	var made provider.RecordSetChanges
+	for _, record := range changes.Update {
+		updated, changed, err := c.updateRecord(ctx, zoneID, existingByID[record.ID], record)
+		if err != nil {
+			return provider.RecordSetChanges{}, err
+		}
+		if changed {
+			made.Update = append(made.Update, *updated)
+		}
+	}
+	for _, record := range changes.Create {
+		created, err := c.createRecord(ctx, zoneID, record)
+		if err != nil {
+			return provider.RecordSetChanges{}, err
 		}
+		made.Create = append(made.Create, *created)
 	}
+	for _, record := range changes.Delete {
+		if err := c.deleteRecord(ctx, zoneID, existingByID[record.ID]); err != nil {
+			return provider.RecordSetChanges{}, err
+		}
+	}
+	made.Delete = changes.Delete
+
+	if err := c.registry.claim(ctx, zoneID, records[0], entry); err != nil {
+		return provider.RecordSetChanges{}, err
+	}
+	return made, nil
+}
 
-	// No record-owner-id found
-	return ""
+// toRecords converts Cloudflare DNS records to provider records
+func toRecords(cfRecords []cloudflare.DNSRecord) []provider.Record {
+	records := make([]provider.Record, 0, len(cfRecords))
+	for _, cfRecord := range cfRecords {
+		records = append(records, toRecord(cfRecord))
+	}
+	return records
 }
 
-// hasOwnerConflict returns true if there is an owner conflict
-func hasOwnerConflict(current DNSRecord, new DNSRecord) bool {
-	if current.OwnerID != "" && current.OwnerID != new.OwnerID {
-		return true
+// toRecord converts a Cloudflare DNS record to a provider record
+func toRecord(cfRecord cloudflare.DNSRecord) provider.Record {
+	record := provider.Record{
+		ID:      cfRecord.ID,
+		Type:    provider.RecordType(cfRecord.Type),
+		Name:    cfRecord.Name,
+		Content: cfRecord.Content,
+		TTL:     cfRecord.TTL,
+		Proxied: cfRecord.Proxied != nil && *cfRecord.Proxied,
 	}
-	return false
+	applyMetadata(&record, parseMetadata(cfRecord.Comment))
+	return record
 }
@@ -0,0 +1,275 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/starttoaster/routeflare/pkg/cloudflare/cloudflaretest"
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+func TestMain(m *testing.M) {
+	slogs.Init("error")
+	os.Exit(m.Run())
+}
+
+// newTestClient returns a client for a fake API server with a zone named example.com, and the zone's ID
+func newTestClient(t *testing.T, opts ...Option) (*Client, *cloudflaretest.Server, string) {
+	t.Helper()
+
+	server := cloudflaretest.NewServer()
+	t.Cleanup(server.Close)
+	zoneID := server.AddZone("example.com")
+
+	client, err := NewClient("test-token", append(opts, WithBaseURL(server.URL))...)
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	// Lift the default limit of 4 requests per second, and retry rate limited requests without waiting, so tests run quickly
+	client.api, err = cloudflare.NewWithAPIToken("test-token",
+		cloudflare.BaseURL(server.URL),
+		cloudflare.UsingRateLimit(1000),
+		cloudflare.UsingRetryPolicy(3, 0, 0))
+	if err != nil {
+		t.Fatalf("creating API client: %v", err)
+	}
+	return client, server, zoneID
+}
+
+func testRecord(content string) provider.Record {
+	return provider.Record{
+		Type:    provider.RecordTypeA,
+		Name:    "app.example.com",
+		Content: content,
+		TTL:     1,
+		OwnerID: "routeflare",
+	}
+}
+
+func TestUpsertRecordCreates(t *testing.T) {
+	client, server, zoneID := newTestClient(t)
+
+	if _, err := client.UpsertRecord(context.Background(), zoneID, testRecord("192.0.2.1")); err != nil {
+		t.Fatalf("UpsertRecord: %v", err)
+	}
+
+	records := server.Records(zoneID)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if records[0].Content != "192.0.2.1" || records[0].Comment != "record-owner-id=routeflare" {
+		t.Errorf("got record with content %q and comment %q", records[0].Content, records[0].Comment)
+	}
+}
+
+func TestUpsertRecordOwnership(t *testing.T) {
+	tests := []struct {
+		name        string
+		comment     string
+		wantErr     error
+		wantContent string
+		wantComment string
+	}{
+		{
+			name:        "owned by someone else",
+			comment:     "record-owner-id=someone-else",
+			wantErr:     provider.ErrOwnershipConflict,
+			wantContent: "192.0.2.1",
+			wantComment: "record-owner-id=someone-else",
+		},
+		{
+			name:        "owned by us",
+			comment:     "record-owner-id=routeflare",
+			wantContent: "192.0.2.2",
+			wantComment: "record-owner-id=routeflare",
+		},
+		{
+			name:        "unowned is adopted",
+			comment:     "",
+			wantContent: "192.0.2.2",
+			wantComment: "record-owner-id=routeflare",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server, zoneID := newTestClient(t)
+			server.AddRecord(zoneID, cloudflare.DNSRecord{
+				Type:    "A",
+				Name:    "app.example.com",
+				Content: "192.0.2.1",
+				TTL:     1,
+				Comment: tt.comment,
+			})
+
+			_, err := client.UpsertRecord(context.Background(), zoneID, testRecord("192.0.2.2"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpsertRecord error = %v, want %v", err, tt.wantErr)
+			}
+
+			records := server.Records(zoneID)
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			if records[0].Content != tt.wantContent || records[0].Comment != tt.wantComment {
+				t.Errorf("got record with content %q and comment %q, want %q and %q",
+					records[0].Content, records[0].Comment, tt.wantContent, tt.wantComment)
+			}
+		})
+	}
+}
+
+func TestUpsertRecordSetPaginates(t *testing.T) {
+	client, server, zoneID := newTestClient(t)
+
+	// More records than fit on one page of results, so a stale record on a later page is only seen by following pagination
+	const existing = 250
+	for i := 1; i <= existing; i++ {
+		record := testRecord(fmt.Sprintf("192.0.2.%d", i))
+		server.AddRecord(zoneID, cloudflare.DNSRecord{
+			Type:    string(record.Type),
+			Name:    record.Name,
+			Content: record.Content,
+			TTL:     record.TTL,
+			Comment: "record-owner-id=routeflare",
+		})
+	}
+
+	records, err := client.ListRecords(context.Background(), zoneID)
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	if len(records) != existing {
+		t.Fatalf("ListRecords got %d records, want %d", len(records), existing)
+	}
+
+	changes, err := client.UpsertRecordSet(context.Background(), zoneID, []provider.Record{testRecord("192.0.2.1")})
+	if err != nil {
+		t.Fatalf("UpsertRecordSet: %v", err)
+	}
+	if len(changes.Create) != 0 || len(changes.Update) != 0 || len(changes.Delete) != existing-1 {
+		t.Errorf("got %d created, %d updated, %d deleted, want 0, 0, %d",
+			len(changes.Create), len(changes.Update), len(changes.Delete), existing-1)
+	}
+	if remaining := server.Records(zoneID); len(remaining) != 1 || remaining[0].Content != "192.0.2.1" {
+		t.Errorf("got remaining records %+v, want only 192.0.2.1", remaining)
+	}
+}
+
+func TestUpsertRecordRateLimited(t *testing.T) {
+	t.Run("retried", func(t *testing.T) {
+		client, server, zoneID := newTestClient(t)
+		server.RateLimitNext(2)
+
+		if _, err := client.UpsertRecord(context.Background(), zoneID, testRecord("192.0.2.1")); err != nil {
+			t.Fatalf("UpsertRecord: %v", err)
+		}
+		if records := server.Records(zoneID); len(records) != 1 {
+			t.Errorf("got %d records, want 1", len(records))
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		client, server, zoneID := newTestClient(t)
+		server.RateLimitNext(100)
+
+		if _, err := client.UpsertRecord(context.Background(), zoneID, testRecord("192.0.2.1")); err == nil {
+			t.Fatal("UpsertRecord succeeded, want a rate limit error")
+		}
+		if records := server.Records(zoneID); len(records) != 0 {
+			t.Errorf("got %d records, want 0", len(records))
+		}
+	})
+}
+
+func TestUpsertRecordSetTXTRegistry(t *testing.T) {
+	client, server, zoneID := newTestClient(t, WithTXTRegistry())
+
+	changes, err := client.UpsertRecordSet(context.Background(), zoneID, []provider.Record{testRecord("192.0.2.1"), testRecord("192.0.2.2")})
+	if err != nil {
+		t.Fatalf("UpsertRecordSet: %v", err)
+	}
+	if len(changes.Create) != 2 {
+		t.Errorf("got %d created, want 2", len(changes.Create))
+	}
+
+	var owners int
+	for _, record := range server.Records(zoneID) {
+		if record.Type == "TXT" && record.Name == "_routeflare-a.app.example.com" {
+			owners++
+		}
+		if record.Type == "A" && record.Comment != "" {
+			t.Errorf("got comment %q on A record with the TXT registry", record.Comment)
+		}
+	}
+	if owners != 1 {
+		t.Errorf("got %d owner TXT records, want 1", owners)
+	}
+}
+
+func TestUpsertRecordSetTXTRegistryOwnedBySomeoneElse(t *testing.T) {
+	client, server, zoneID := newTestClient(t, WithTXTRegistry())
+	server.AddRecord(zoneID, cloudflare.DNSRecord{
+		Type:    "TXT",
+		Name:    "_routeflare-a.app.example.com",
+		Content: `"record-owner-id=someone-else"`,
+		TTL:     1,
+	})
+
+	// The TXT record claims the name before it has any A records
+	_, err := client.UpsertRecordSet(context.Background(), zoneID, []provider.Record{testRecord("192.0.2.1")})
+	if !errors.Is(err, provider.ErrOwnershipConflict) {
+		t.Fatalf("UpsertRecordSet error = %v, want an ownership conflict", err)
+	}
+
+	records := server.Records(zoneID)
+	if len(records) != 1 || records[0].Content != `"record-owner-id=someone-else"` {
+		t.Errorf("got records %+v, want only the other owner's TXT record", records)
+	}
+}
+
+func TestDeleteRecordTXTRegistryReleasesEntryWithoutRecords(t *testing.T) {
+	client, server, zoneID := newTestClient(t, WithTXTRegistry())
+	server.AddRecord(zoneID, cloudflare.DNSRecord{
+		Type:    "TXT",
+		Name:    "_routeflare-a.app.example.com",
+		Content: `"record-owner-id=routeflare"`,
+		TTL:     1,
+	})
+
+	// The A records are gone, but their registry entry was left behind
+	if _, err := client.DeleteRecord(context.Background(), zoneID, testRecord("")); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if records := server.Records(zoneID); len(records) != 0 {
+		t.Errorf("got records %+v, want the registry entry deleted", records)
+	}
+}
+
+func TestUpsertRecordSetTXTRegistryUnchanged(t *testing.T) {
+	client, server, zoneID := newTestClient(t, WithTXTRegistry())
+	records := []provider.Record{testRecord("192.0.2.1")}
+	if _, err := client.UpsertRecordSet(context.Background(), zoneID, records); err != nil {
+		t.Fatalf("UpsertRecordSet: %v", err)
+	}
+
+	// Publishing the same record set again only reads the set and its TXT record
+	before := server.Requests()
+	changes, err := client.UpsertRecordSet(context.Background(), zoneID, records)
+	if err != nil {
+		t.Fatalf("UpsertRecordSet: %v", err)
+	}
+	if len(changes.Create) != 0 || len(changes.Update) != 0 || len(changes.Delete) != 0 {
+		t.Errorf("got changes %+v, want none", changes)
+	}
+	if requests := server.Requests() - before; requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
@@ -0,0 +1,492 @@
+// Package cloudflaretest provides a fake Cloudflare v4 API server for exercising the Cloudflare client without a Cloudflare account
+package cloudflaretest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const (
+	defaultPerPage = 100
+	maxPerPage     = 5000
+)
+
+// Server is a fake Cloudflare v4 API serving zones and DNS records from memory
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	zones       []cloudflare.Zone
+	records     map[string][]cloudflare.DNSRecord // zone ID -> records
+	rateLimited int                               // number of upcoming requests to reject with a 429
+	requests    int
+	zoneLists   int // number of zone list requests, including rate limited ones
+	writes      int // number of requests that aren't reads, including rate limited ones
+	nextID      int
+}
+
+// NewServer starts a new fake Cloudflare API server with no zones
+// Callers should Close the server when finished with it
+func NewServer() *Server {
+	s := &Server{
+		records: make(map[string][]cloudflare.DNSRecord),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /zones", s.listZones)
+	mux.HandleFunc("GET /zones/{zoneID}/dns_records", s.listRecords)
+	mux.HandleFunc("POST /zones/{zoneID}/dns_records", s.createRecord)
+	mux.HandleFunc("GET /zones/{zoneID}/dns_records/{recordID}", s.getRecord)
+	mux.HandleFunc("PATCH /zones/{zoneID}/dns_records/{recordID}", s.updateRecord)
+	mux.HandleFunc("PUT /zones/{zoneID}/dns_records/{recordID}", s.updateRecord)
+	mux.HandleFunc("DELETE /zones/{zoneID}/dns_records/{recordID}", s.deleteRecord)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// AddZone adds a zone to the server and returns its ID
+func (s *Server) AddZone(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone := cloudflare.Zone{
+		ID:   s.newID(),
+		Name: strings.ToLower(name),
+	}
+	s.zones = append(s.zones, zone)
+	s.records[zone.ID] = nil
+	return zone.ID
+}
+
+// RemoveZone removes a zone and its records from the server, as if it had been deleted from the account
+func (s *Server) RemoveZone(zoneID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, zone := range s.zones {
+		if zone.ID == zoneID {
+			s.zones = append(s.zones[:i], s.zones[i+1:]...)
+			break
+		}
+	}
+	delete(s.records, zoneID)
+}
+
+// AddRecord adds a DNS record to a zone as if it had been created outside of routeflare, and returns it with its ID set
+// Records are stored under the ID of their zone, since cloudflare.DNSRecord doesn't carry one
+func (s *Server) AddRecord(zoneID string, record cloudflare.DNSRecord) cloudflare.DNSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.ID = s.newID()
+	s.records[zoneID] = append(s.records[zoneID], record)
+	return record
+}
+
+// Records returns a copy of the DNS records in a zone
+func (s *Server) Records(zoneID string) []cloudflare.DNSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]cloudflare.DNSRecord(nil), s.records[zoneID]...)
+}
+
+// RateLimitNext makes the server reject the next n requests with a 429 Too Many Requests response
+func (s *Server) RateLimitNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimited = n
+}
+
+// Requests returns the number of requests the server has received, including rate limited ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// ZoneListRequests returns the number of zone list requests the server has received, including rate limited ones
+func (s *Server) ZoneListRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.zoneLists
+}
+
+// WriteRequests returns the number of requests the server has received that create, update, or delete something,
+// including rate limited ones
+func (s *Server) WriteRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writes
+}
+
+// middleware counts requests and applies rate limiting before passing requests to the API handlers
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		if r.Method == http.MethodGet && r.URL.Path == "/zones" {
+			s.zoneLists++
+		}
+		if r.Method != http.MethodGet {
+			s.writes++
+		}
+		limited := s.rateLimited > 0
+		if limited {
+			s.rateLimited--
+		}
+		s.mu.Unlock()
+
+		if limited {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, 971, "Please wait and consider throttling your request speed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listZones handles GET /zones
+func (s *Server) listZones(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	zones := []cloudflare.Zone{}
+	for _, zone := range s.zones {
+		if name == "" || zone.Name == name {
+			zones = append(zones, zone)
+		}
+	}
+
+	page, info, err := paginate(r, len(zones))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 1001, err.Error())
+		return
+	}
+	writeResult(w, http.StatusOK, zones[page[0]:page[1]], &info)
+}
+
+// listRecords handles GET /zones/{zoneID}/dns_records
+func (s *Server) listRecords(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zoneRecords, ok := s.zoneRecords(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	name := strings.ToLower(query.Get("name"))
+	if name == "" {
+		name = strings.ToLower(query.Get("name.exact"))
+	}
+	recordType := strings.ToUpper(query.Get("type"))
+	content := query.Get("content")
+	comment := query.Get("comment")
+
+	records := []cloudflare.DNSRecord{}
+	for _, record := range zoneRecords {
+		if name != "" && record.Name != name {
+			continue
+		}
+		if recordType != "" && record.Type != recordType {
+			continue
+		}
+		if content != "" && record.Content != content {
+			continue
+		}
+		if comment != "" && record.Comment != comment {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	page, info, err := paginate(r, len(records))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 1004, err.Error())
+		return
+	}
+	writeResult(w, http.StatusOK, records[page[0]:page[1]], &info)
+}
+
+// getRecord handles GET /zones/{zoneID}/dns_records/{recordID}
+func (s *Server) getRecord(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zoneRecords, ok := s.zoneRecords(w, r)
+	if !ok {
+		return
+	}
+
+	i := findRecordIndex(zoneRecords, r.PathValue("recordID"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 81044, "Record does not exist.")
+		return
+	}
+	writeResult(w, http.StatusOK, zoneRecords[i], nil)
+}
+
+// createRecord handles POST /zones/{zoneID}/dns_records
+func (s *Server) createRecord(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zoneID := r.PathValue("zoneID")
+	zoneRecords, ok := s.zoneRecords(w, r)
+	if !ok {
+		return
+	}
+
+	var params cloudflare.CreateDNSRecordParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, 9207, "Request body is invalid.")
+		return
+	}
+
+	record := cloudflare.DNSRecord{
+		ID:      s.newID(),
+		Type:    strings.ToUpper(params.Type),
+		Name:    strings.ToLower(params.Name),
+		Content: params.Content,
+		TTL:     params.TTL,
+		Proxied: params.Proxied,
+		Comment: params.Comment,
+	}
+	if code, msg := validateRecord(zoneRecords, record); code != 0 {
+		writeError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	s.records[zoneID] = append(zoneRecords, record)
+	writeResult(w, http.StatusOK, record, nil)
+}
+
+// updateRecordParams holds the fields of a record that may be updated, nil fields are left unchanged
+type updateRecordParams struct {
+	Type    *string `json:"type"`
+	Name    *string `json:"name"`
+	Content *string `json:"content"`
+	TTL     *int    `json:"ttl"`
+	Proxied *bool   `json:"proxied"`
+	Comment *string `json:"comment"`
+}
+
+// updateRecord handles PATCH and PUT /zones/{zoneID}/dns_records/{recordID}
+func (s *Server) updateRecord(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zoneID := r.PathValue("zoneID")
+	zoneRecords, ok := s.zoneRecords(w, r)
+	if !ok {
+		return
+	}
+
+	i := findRecordIndex(zoneRecords, r.PathValue("recordID"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 81044, "Record does not exist.")
+		return
+	}
+
+	var params updateRecordParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, 9207, "Request body is invalid.")
+		return
+	}
+
+	record := zoneRecords[i]
+	if params.Type != nil {
+		record.Type = strings.ToUpper(*params.Type)
+	}
+	if params.Name != nil {
+		record.Name = strings.ToLower(*params.Name)
+	}
+	if params.Content != nil {
+		record.Content = *params.Content
+	}
+	if params.TTL != nil {
+		record.TTL = *params.TTL
+	}
+	if params.Proxied != nil {
+		proxied := *params.Proxied
+		record.Proxied = &proxied
+	}
+	if params.Comment != nil {
+		record.Comment = *params.Comment
+	}
+
+	others := append(append([]cloudflare.DNSRecord(nil), zoneRecords[:i]...), zoneRecords[i+1:]...)
+	if code, msg := validateRecord(others, record); code != 0 {
+		writeError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	s.records[zoneID][i] = record
+	writeResult(w, http.StatusOK, record, nil)
+}
+
+// deleteRecord handles DELETE /zones/{zoneID}/dns_records/{recordID}
+func (s *Server) deleteRecord(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zoneID := r.PathValue("zoneID")
+	zoneRecords, ok := s.zoneRecords(w, r)
+	if !ok {
+		return
+	}
+
+	recordID := r.PathValue("recordID")
+	i := findRecordIndex(zoneRecords, recordID)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, 81044, "Record does not exist.")
+		return
+	}
+
+	s.records[zoneID] = append(zoneRecords[:i], zoneRecords[i+1:]...)
+	writeResult(w, http.StatusOK, map[string]string{"id": recordID}, nil)
+}
+
+// zoneRecords returns the records of the zone in the request path, writing a 404 if the zone does not exist
+// Callers must hold the lock
+func (s *Server) zoneRecords(w http.ResponseWriter, r *http.Request) ([]cloudflare.DNSRecord, bool) {
+	zoneRecords, ok := s.records[r.PathValue("zoneID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 7003, "Could not route to "+r.URL.Path+", perhaps your object identifier is invalid?")
+		return nil, false
+	}
+	return zoneRecords, true
+}
+
+// newID returns a new unique identifier, callers must hold the lock
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%032x", s.nextID)
+}
+
+// validateRecord checks a record against the other records in its zone the way the Cloudflare API does
+func validateRecord(others []cloudflare.DNSRecord, record cloudflare.DNSRecord) (int, string) {
+	if record.Type == "" || record.Name == "" || record.Content == "" {
+		return 9000, "DNS record type, name and content are required."
+	}
+
+	for _, other := range others {
+		if other.Name != record.Name {
+			continue
+		}
+		if other.Type == "CNAME" || record.Type == "CNAME" {
+			return 81053, "An A, AAAA, or CNAME record with that host already exists."
+		}
+		if other.Type == record.Type && other.Content == record.Content {
+			return 81058, "An identical record already exists."
+		}
+	}
+	return 0, ""
+}
+
+// findRecordIndex returns the index of the record with the given ID, or -1 if it does not exist
+func findRecordIndex(records []cloudflare.DNSRecord, recordID string) int {
+	for i, record := range records {
+		if record.ID == recordID {
+			return i
+		}
+	}
+	return -1
+}
+
+// paginate returns the start and end indexes of the requested page along with its result info
+func paginate(r *http.Request, total int) ([2]int, cloudflare.ResultInfo, error) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		return [2]int{}, cloudflare.ResultInfo{}, err
+	}
+	perPage, err := queryInt(r, "per_page", defaultPerPage)
+	if err != nil {
+		return [2]int{}, cloudflare.ResultInfo{}, err
+	}
+	if page < 1 || perPage < 1 || perPage > maxPerPage {
+		return [2]int{}, cloudflare.ResultInfo{}, fmt.Errorf("invalid pagination parameters")
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	info := cloudflare.ResultInfo{
+		Page:       page,
+		PerPage:    perPage,
+		Count:      end - start,
+		Total:      total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+	return [2]int{start, end}, info, nil
+}
+
+// queryInt parses an integer query parameter, returning def if it is unset
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return i, nil
+}
+
+// response is the envelope the Cloudflare v4 API wraps every result in
+type response struct {
+	Success    bool                      `json:"success"`
+	Errors     []cloudflare.ResponseInfo `json:"errors"`
+	Messages   []cloudflare.ResponseInfo `json:"messages"`
+	Result     interface{}               `json:"result"`
+	ResultInfo *cloudflare.ResultInfo    `json:"result_info,omitempty"`
+}
+
+// writeResult writes a successful API response
+func writeResult(w http.ResponseWriter, status int, result interface{}, info *cloudflare.ResultInfo) {
+	writeJSON(w, status, response{
+		Success:    true,
+		Errors:     []cloudflare.ResponseInfo{},
+		Messages:   []cloudflare.ResponseInfo{},
+		Result:     result,
+		ResultInfo: info,
+	})
+}
+
+// writeError writes a failed API response with a single error
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, response{
+		Success:  false,
+		Errors:   []cloudflare.ResponseInfo{{Code: code, Message: message}},
+		Messages: []cloudflare.ResponseInfo{},
+	})
+}
+
+// writeJSON writes a JSON body with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,57 @@
+package cloudflare
+
+import (
+	"fmt"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// logDryRunCreate logs the record a create would have made
+func logDryRunCreate(params cloudflare.CreateDNSRecordParams) {
+	slogs.Logr.Info("Dry run: would create record",
+		"type", params.Type,
+		"name", params.Name,
+		"content", params.Content,
+		"ttl", params.TTL,
+		"proxied", params.Proxied != nil && *params.Proxied,
+		"comment", params.Comment)
+}
+
+// logDryRunUpdate logs the fields an update would have changed, formatted as "old -> new"
+func logDryRunUpdate(current cloudflare.DNSRecord, params cloudflare.UpdateDNSRecordParams) {
+	args := []any{
+		"id", current.ID,
+		"type", current.Type,
+		"name", current.Name,
+	}
+
+	currentProxied := current.Proxied != nil && *current.Proxied
+	proxied := params.Proxied != nil && *params.Proxied
+	for _, field := range []struct {
+		key      string
+		old, new any
+	}{
+		{"content", current.Content, params.Content},
+		{"ttl", current.TTL, params.TTL},
+		{"proxied", currentProxied, proxied},
+	} {
+		if field.old != field.new {
+			args = append(args, field.key, fmt.Sprintf("%v -> %v", field.old, field.new))
+		}
+	}
+	if params.Comment != nil && current.Comment != *params.Comment {
+		args = append(args, "comment", fmt.Sprintf("%q -> %q", current.Comment, *params.Comment))
+	}
+
+	slogs.Logr.Info("Dry run: would update record", args...)
+}
+
+// logDryRunDelete logs the record a delete would have removed
+func logDryRunDelete(record cloudflare.DNSRecord) {
+	slogs.Logr.Info("Dry run: would delete record",
+		"id", record.ID,
+		"type", record.Type,
+		"name", record.Name,
+		"content", record.Content)
+}
@@ -0,0 +1,59 @@
+package cloudflare
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+func TestDryRunMakesNoWrites(t *testing.T) {
+	for _, txtRegistry := range []bool{false, true} {
+		opts := []Option{WithDryRun()}
+		if txtRegistry {
+			opts = append(opts, WithTXTRegistry())
+		}
+		client, server, zoneID := newTestClient(t, opts...)
+		ctx := context.Background()
+
+		stale := testRecord("192.0.2.9")
+		stale.Name = "stale.example.com"
+		server.AddRecord(zoneID, cloudflare.DNSRecord{Type: "A", Name: "app.example.com", Content: "192.0.2.1", TTL: 1, Comment: "record-owner-id=routeflare"})
+		server.AddRecord(zoneID, cloudflare.DNSRecord{Type: "A", Name: "app.example.com", Content: "192.0.2.2", TTL: 1, Comment: "record-owner-id=routeflare"})
+		server.AddRecord(zoneID, cloudflare.DNSRecord{Type: "A", Name: "stale.example.com", Content: "192.0.2.9", TTL: 1, Comment: "record-owner-id=routeflare"})
+		for _, name := range []string{"_routeflare-a.app.example.com", "_routeflare-a.stale.example.com"} {
+			server.AddRecord(zoneID, cloudflare.DNSRecord{Type: "TXT", Name: name, Content: `"record-owner-id=routeflare"`, TTL: 1})
+		}
+		before := server.Records(zoneID)
+
+		// The set has a record to update, one to delete, and one to create
+		changes, err := client.UpsertRecordSet(ctx, zoneID, []provider.Record{testRecord("192.0.2.1"), testRecord("192.0.2.3")})
+		if err != nil {
+			t.Fatalf("UpsertRecordSet: %v", err)
+		}
+		if len(changes.Update)+len(changes.Delete)+len(changes.Create) == 0 {
+			t.Errorf("got no changes in dry run mode, want the changes that would have been made")
+		}
+		created := testRecord("192.0.2.4")
+		created.Name = "new.example.com"
+		if _, err := client.UpsertRecord(ctx, zoneID, created); err != nil {
+			t.Fatalf("UpsertRecord: %v", err)
+		}
+		if _, err := client.UpsertRecordSet(ctx, zoneID, []provider.Record{created}); err != nil {
+			t.Fatalf("UpsertRecordSet: %v", err)
+		}
+		if _, err := client.DeleteRecord(ctx, zoneID, stale); err != nil {
+			t.Fatalf("DeleteRecord: %v", err)
+		}
+
+		if writes := server.WriteRequests(); writes != 0 {
+			t.Errorf("TXT registry %v: got %d write requests in dry run mode, want none", txtRegistry, writes)
+		}
+		if after := server.Records(zoneID); !reflect.DeepEqual(after, before) {
+			t.Errorf("TXT registry %v: got records %+v, want them unchanged %+v", txtRegistry, after, before)
+		}
+	}
+}
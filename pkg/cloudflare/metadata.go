@@ -0,0 +1,74 @@
+package cloudflare
+
+import (
+	"strings"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+const (
+	// maxCommentLength is the longest record comment allowed on Cloudflare's free plan
+	maxCommentLength = 100
+
+	metadataKeyOwnerID     = "record-owner-id"
+	metadataKeyKind        = "kind"
+	metadataKeyNamespace   = "namespace"
+	metadataKeyName        = "name"
+	metadataKeyContentMode = "content-mode"
+	metadataKeyUID         = "uid"
+)
+
+// formatMetadata formats a record's owner and source into space separated key=value pairs
+// Format: "record-owner-id=$ownerID kind=$kind namespace=$namespace name=$name content-mode=$contentMode uid=$uid"
+// Trailing pairs are dropped until the result fits in maxLength, but the owner ID is always kept
+func formatMetadata(record provider.Record, maxLength int) string {
+	if record.OwnerID == "" {
+		return ""
+	}
+
+	metadata := metadataKeyOwnerID + "=" + record.OwnerID
+	for _, pair := range [][2]string{
+		{metadataKeyKind, record.Source.Kind},
+		{metadataKeyNamespace, record.Source.Namespace},
+		{metadataKeyName, record.Source.Name},
+		{metadataKeyContentMode, record.Source.ContentMode},
+		{metadataKeyUID, record.Source.UID},
+	} {
+		if pair[1] == "" {
+			continue
+		}
+		next := metadata + " " + pair[0] + "=" + pair[1]
+		if len(next) > maxLength {
+			break
+		}
+		metadata = next
+	}
+	return metadata
+}
+
+// parseMetadata decodes space separated key=value pairs, ignoring anything that isn't a pair
+// Format: "key=value key2=value2 key3=value3"
+func parseMetadata(metadata string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Fields(metadata) {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// applyMetadata fills in a record's owner and source from decoded metadata
+// Metadata written before sources were tracked only has an owner ID, which leaves the source empty
+func applyMetadata(record *provider.Record, metadata map[string]string) {
+	record.OwnerID = metadata[metadataKeyOwnerID]
+	record.Source = provider.Source{
+		Kind:        metadata[metadataKeyKind],
+		Namespace:   metadata[metadataKeyNamespace],
+		Name:        metadata[metadataKeyName],
+		UID:         metadata[metadataKeyUID],
+		ContentMode: metadata[metadataKeyContentMode],
+	}
+}
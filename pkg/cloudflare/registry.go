@@ -0,0 +1,198 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+// registry tracks which owner each DNS record belongs to
+type registry interface {
+	// records converts Cloudflare records to provider records with their owners filled in
+	// complete is true when cfRecords holds every record in the zone, so nothing else needs to be looked up
+	records(ctx context.Context, zoneID string, cfRecords []cloudflare.DNSRecord, complete bool) ([]provider.Record, error)
+	// lookup converts the Cloudflare records of a record set to provider records with their owners filled in, and returns the registry's
+	// entry for the set, or nil if it has none
+	// The entry is passed back to claim and release, so the registry is only read once for each change to the set
+	lookup(ctx context.Context, zoneID string, set provider.Record, cfRecords []cloudflare.DNSRecord) ([]provider.Record, *registryEntry, error)
+	// comment returns the comment to write on a record, or nil to leave the record's comment alone
+	comment(record provider.Record) *string
+	// claim records ownership of a record set after it is created or updated
+	claim(ctx context.Context, zoneID string, record provider.Record, entry *registryEntry) error
+	// release removes ownership of a record set after it is deleted
+	release(ctx context.Context, zoneID string, record provider.Record, entry *registryEntry) error
+}
+
+// registryEntry is a registry's record of who owns a record set, which is kept apart from the set's own records,
+// so a record set can be owned before it has any records
+type registryEntry struct {
+	owner     provider.Record        // The owner and source the entry holds
+	cfRecords []cloudflare.DNSRecord // The records holding the entry
+}
+
+// checkOwnership returns an ownership conflict error if any of a record set's current records, or its registry entry,
+// is owned by someone other than the owner of the desired record
+func checkOwnership(current []provider.Record, entry *registryEntry, desired provider.Record) error {
+	if entry != nil && provider.HasOwnerConflict(entry.owner, desired) {
+		return provider.NewOwnershipConflictError(entry.owner, desired)
+	}
+	return provider.CheckRecordSetOwnership(current, desired)
+}
+
+// commentRegistry stores record ownership in each record's comment
+type commentRegistry struct{}
+
+func (commentRegistry) records(_ context.Context, _ string, cfRecords []cloudflare.DNSRecord, _ bool) ([]provider.Record, error) {
+	return toRecords(cfRecords), nil
+}
+
+func (commentRegistry) lookup(_ context.Context, _ string, _ provider.Record, cfRecords []cloudflare.DNSRecord) ([]provider.Record, *registryEntry, error) {
+	return toRecords(cfRecords), nil, nil // Each record holds its own owner
+}
+
+func (commentRegistry) comment(record provider.Record) *string {
+	comment := formatMetadata(record, maxCommentLength)
+	return &comment
+}
+
+func (commentRegistry) claim(_ context.Context, _ string, _ provider.Record, _ *registryEntry) error {
+	return nil // The comment is written along with the record
+}
+
+func (commentRegistry) release(_ context.Context, _ string, _ provider.Record, _ *registryEntry) error {
+	return nil // The comment is deleted along with the record
+}
+
+const (
+	txtRegistryPrefix = "_routeflare-"
+	// maxTXTStringLength is the longest character string allowed in TXT record content, less the quotes around it
+	maxTXTStringLength = 253
+)
+
+// txtRegistry stores record ownership in a companion TXT record for each record set, similar to external-dns
+// The companion of "app.example.com" A records is the TXT record "_routeflare-a.app.example.com"
+// Record comments are left alone, so they are free to be edited in the Cloudflare dashboard
+type txtRegistry struct {
+	client *Client
+}
+
+func (r *txtRegistry) records(ctx context.Context, zoneID string, cfRecords []cloudflare.DNSRecord, complete bool) ([]provider.Record, error) {
+	metadataByName := make(map[string]map[string]string)
+	for _, cfRecord := range cfRecords {
+		if cfRecord.Type == "TXT" {
+			metadataByName[strings.ToLower(cfRecord.Name)] = parseTXTRegistryContent(cfRecord.Content)
+		}
+	}
+
+	records := make([]provider.Record, 0, len(cfRecords))
+	for _, cfRecord := range cfRecords {
+		record := toRecord(cfRecord)
+		if cfRecord.Type == "TXT" {
+			applyMetadata(&record, metadataByName[strings.ToLower(cfRecord.Name)])
+			records = append(records, record)
+			continue
+		}
+
+		txtName := strings.ToLower(txtRegistryRecordName(record.Name, record.Type))
+		metadata, ok := metadataByName[txtName]
+		if !ok && !complete {
+			txtRecords, err := r.client.findRecords(ctx, zoneID, txtName, "TXT")
+			if err != nil {
+				return nil, fmt.Errorf("error finding registry record: %w", err)
+			}
+			if len(txtRecords) > 0 {
+				metadata = parseTXTRegistryContent(txtRecords[0].Content)
+			}
+			metadataByName[txtName] = metadata
+		}
+		applyMetadata(&record, metadata)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (r *txtRegistry) lookup(ctx context.Context, zoneID string, set provider.Record, cfRecords []cloudflare.DNSRecord) ([]provider.Record, *registryEntry, error) {
+	txtRecords, err := r.client.findRecords(ctx, zoneID, txtRegistryRecordName(set.Name, set.Type), "TXT")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding registry record: %w", err)
+	}
+
+	var entry *registryEntry
+	var metadata map[string]string
+	if len(txtRecords) > 0 {
+		metadata = parseTXTRegistryContent(txtRecords[0].Content)
+		entry = &registryEntry{cfRecords: txtRecords}
+		applyMetadata(&entry.owner, metadata)
+	}
+
+	records := make([]provider.Record, 0, len(cfRecords))
+	for _, cfRecord := range cfRecords {
+		record := toRecord(cfRecord)
+		applyMetadata(&record, metadata)
+		records = append(records, record)
+	}
+	return records, entry, nil
+}
+
+func (r *txtRegistry) comment(_ provider.Record) *string {
+	return nil
+}
+
+func (r *txtRegistry) claim(ctx context.Context, zoneID string, record provider.Record, entry *registryEntry) error {
+	txtRecord := provider.Record{
+		Type:    "TXT",
+		Name:    txtRegistryRecordName(record.Name, record.Type),
+		Content: formatTXTRegistryContent(record),
+		TTL:     1,
+		OwnerID: record.OwnerID,
+		Source:  record.Source,
+	}
+
+	// Ownership of an existing entry was already checked by the caller, and an entry that is up to date isn't written
+	var err error
+	if entry != nil {
+		_, _, err = r.client.updateRecord(ctx, zoneID, entry.cfRecords[0], txtRecord)
+	} else {
+		_, err = r.client.createRecord(ctx, zoneID, txtRecord)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing registry record: %w", err)
+	}
+	return nil
+}
+
+func (r *txtRegistry) release(ctx context.Context, zoneID string, _ provider.Record, entry *registryEntry) error {
+	if entry == nil {
+		return nil
+	}
+
+	for _, cfRecord := range entry.cfRecords {
+		if err := r.client.deleteRecord(ctx, zoneID, cfRecord); err != nil {
+			return fmt.Errorf("error deleting registry record: %w", err)
+		}
+	}
+	return nil
+}
+
+// txtRegistryRecordName returns the name of the TXT record holding ownership of a record set
+// A wildcard can only be the leftmost label of a name, so it is swapped for a plain label
+func txtRegistryRecordName(recordName string, recordType provider.RecordType) string {
+	if strings.HasPrefix(recordName, "*.") {
+		recordName = "_wildcard." + strings.TrimPrefix(recordName, "*.")
+	}
+	return txtRegistryPrefix + strings.ToLower(string(recordType)) + "." + recordName
+}
+
+// formatTXTRegistryContent formats record metadata into quoted TXT record content
+func formatTXTRegistryContent(record provider.Record) string {
+	return `"` + formatMetadata(record, maxTXTStringLength) + `"`
+}
+
+// parseTXTRegistryContent decodes record metadata from TXT record content
+func parseTXTRegistryContent(content string) map[string]string {
+	return parseMetadata(strings.Trim(content, `"`))
+}
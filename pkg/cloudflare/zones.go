@@ -0,0 +1,126 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+const (
+	// defaultZoneCacheTTL is how long the list of zones is cached before it is fetched again
+	defaultZoneCacheTTL = 1 * time.Hour
+	// zoneCacheMinAge is how old the cache must be before a lookup miss causes it to be fetched again
+	// This keeps a route with a hostname outside every zone from listing zones on every reconcile
+	zoneCacheMinAge = 1 * time.Minute
+)
+
+// zoneCache caches every zone the API token can access, and is safe for concurrent use
+type zoneCache struct {
+	ttl       time.Duration
+	mutex     sync.RWMutex
+	zones     []provider.Zone
+	fetchedAt time.Time
+}
+
+// GetZoneIDByName finds a zone ID by its name
+func (c *Client) GetZoneIDByName(zoneName string) (string, error) {
+	zone, err := c.lookupZone(context.Background(), func(zones []provider.Zone) (*provider.Zone, error) {
+		for _, zone := range zones {
+			if strings.EqualFold(zone.Name, strings.TrimSuffix(zoneName, ".")) {
+				return &zone, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", provider.ErrZoneNotFound, zoneName)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting zone ID for %s: %w", zoneName, err)
+	}
+	return zone.ID, nil
+}
+
+// FindZone finds the zone a record name belongs to by matching the longest zone name that is a suffix of the record name
+func (c *Client) FindZone(ctx context.Context, recordName string) (*provider.Zone, error) {
+	return c.lookupZone(ctx, func(zones []provider.Zone) (*provider.Zone, error) {
+		return provider.MatchZone(zones, recordName)
+	})
+}
+
+// lookupZone runs a lookup against the cached zones
+// If the lookup does not find a zone, the cache is refreshed and the lookup is tried once more, in case the zone was recently added
+func (c *Client) lookupZone(ctx context.Context, lookup func([]provider.Zone) (*provider.Zone, error)) (*provider.Zone, error) {
+	zones, fetchedAt, err := c.listZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := lookup(zones)
+	if err == nil || !errors.Is(err, provider.ErrZoneNotFound) || time.Since(fetchedAt) < zoneCacheMinAge {
+		return zone, err
+	}
+
+	c.invalidateZones()
+	zones, _, err = c.listZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lookup(zones)
+}
+
+// listZones returns every zone the API token can access along with when they were fetched, fetching them if the cache is empty or expired
+func (c *Client) listZones(ctx context.Context) ([]provider.Zone, time.Time, error) {
+	c.zoneCache.mutex.RLock()
+	zones, fetchedAt := c.zoneCache.zones, c.zoneCache.fetchedAt
+	c.zoneCache.mutex.RUnlock()
+	if zones != nil && time.Since(fetchedAt) < c.zoneCache.ttl {
+		return zones, fetchedAt, nil
+	}
+
+	c.zoneCache.mutex.Lock()
+	defer c.zoneCache.mutex.Unlock()
+
+	// Another caller may have refreshed the cache while we waited for the lock
+	if c.zoneCache.zones != nil && time.Since(c.zoneCache.fetchedAt) < c.zoneCache.ttl {
+		return c.zoneCache.zones, c.zoneCache.fetchedAt, nil
+	}
+
+	cfZones, err := c.api.ListZones(ctx)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error listing zones: %w", err)
+	}
+
+	zones = make([]provider.Zone, 0, len(cfZones))
+	for _, cfZone := range cfZones {
+		zones = append(zones, provider.Zone{
+			ID:   cfZone.ID,
+			Name: cfZone.Name,
+		})
+	}
+	c.zoneCache.zones = zones
+	c.zoneCache.fetchedAt = time.Now()
+	return c.zoneCache.zones, c.zoneCache.fetchedAt, nil
+}
+
+// invalidateZones clears the zone cache so the next lookup fetches the zones again
+func (c *Client) invalidateZones() {
+	c.zoneCache.mutex.Lock()
+	defer c.zoneCache.mutex.Unlock()
+
+	c.zoneCache.zones = nil
+	c.zoneCache.fetchedAt = time.Time{}
+}
+
+// checkZoneNotFound invalidates the zone cache if an API error says the requested resource was not found
+// This happens when a cached zone has been deleted, or its ID has changed
+func (c *Client) checkZoneNotFound(err error) {
+	var notFound *cloudflare.NotFoundError
+	if errors.As(err, &notFound) {
+		c.invalidateZones()
+	}
+}
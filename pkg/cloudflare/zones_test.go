@@ -0,0 +1,129 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+// ageZoneCache makes the cached zones look like they were fetched the given duration ago
+func ageZoneCache(client *Client, age time.Duration) {
+	client.zoneCache.mutex.Lock()
+	defer client.zoneCache.mutex.Unlock()
+
+	client.zoneCache.fetchedAt = time.Now().Add(-age)
+}
+
+func TestZoneCacheExpires(t *testing.T) {
+	client, server, zoneID := newTestClient(t, WithZoneCacheTTL(10*time.Minute))
+
+	for range 3 {
+		zone, err := client.FindZone(context.Background(), "app.example.com")
+		if err != nil || zone.ID != zoneID {
+			t.Fatalf("FindZone = %+v, %v, want zone %s", zone, err, zoneID)
+		}
+	}
+	if got := server.ZoneListRequests(); got != 1 {
+		t.Fatalf("got %d zone list requests within the TTL, want 1", got)
+	}
+
+	ageZoneCache(client, 11*time.Minute)
+	if _, err := client.GetZoneIDByName("example.com"); err != nil {
+		t.Fatalf("GetZoneIDByName: %v", err)
+	}
+	if got := server.ZoneListRequests(); got != 2 {
+		t.Errorf("got %d zone list requests after the TTL expired, want 2", got)
+	}
+}
+
+func TestZoneCacheRefreshesWhenZoneNotFound(t *testing.T) {
+	client, server, _ := newTestClient(t)
+
+	// A miss right after fetching doesn't list the zones again
+	if _, err := client.FindZone(context.Background(), "app.example.org"); !errors.Is(err, provider.ErrZoneNotFound) {
+		t.Fatalf("FindZone error = %v, want ErrZoneNotFound", err)
+	}
+	if got := server.ZoneListRequests(); got != 1 {
+		t.Fatalf("got %d zone list requests for a miss on a fresh cache, want 1", got)
+	}
+
+	// A miss once the cache is old enough lists the zones again, and finds a newly added zone
+	newZoneID := server.AddZone("example.org")
+	ageZoneCache(client, zoneCacheMinAge+time.Second)
+	zone, err := client.FindZone(context.Background(), "app.example.org")
+	if err != nil || zone.ID != newZoneID {
+		t.Fatalf("FindZone = %+v, %v, want zone %s", zone, err, newZoneID)
+	}
+	if got := server.ZoneListRequests(); got != 2 {
+		t.Errorf("got %d zone list requests, want 2", got)
+	}
+
+	// GetZoneIDByName refreshes the same way
+	otherZoneID := server.AddZone("example.net")
+	ageZoneCache(client, zoneCacheMinAge+time.Second)
+	if got, err := client.GetZoneIDByName("example.net"); err != nil || got != otherZoneID {
+		t.Fatalf("GetZoneIDByName = %q, %v, want %q", got, err, otherZoneID)
+	}
+	if got := server.ZoneListRequests(); got != 3 {
+		t.Errorf("got %d zone list requests, want 3", got)
+	}
+}
+
+func TestZoneCacheInvalidatedOnZoneNotFound(t *testing.T) {
+	client, server, zoneID := newTestClient(t)
+
+	if _, err := client.FindZone(context.Background(), "app.example.com"); err != nil {
+		t.Fatalf("FindZone: %v", err)
+	}
+
+	server.RemoveZone(zoneID)
+	if _, err := client.ListRecords(context.Background(), zoneID); err == nil {
+		t.Fatal("ListRecords succeeded for a removed zone")
+	}
+
+	// The removed zone is no longer served from the cache
+	if _, err := client.FindZone(context.Background(), "app.example.com"); !errors.Is(err, provider.ErrZoneNotFound) {
+		t.Fatalf("FindZone error = %v, want ErrZoneNotFound", err)
+	}
+	if got := server.ZoneListRequests(); got != 2 {
+		t.Errorf("got %d zone list requests, want 2", got)
+	}
+}
+
+func TestZoneCacheParallelLookups(t *testing.T) {
+	client, server, zoneID := newTestClient(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				zone, err := client.FindZone(context.Background(), "app.example.com")
+				if err == nil && zone.ID != zoneID {
+					err = errors.New("FindZone returned the wrong zone " + zone.ID)
+				}
+				errs <- err
+				return
+			}
+			_, err := client.GetZoneIDByName("example.com")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := server.ZoneListRequests(); got != 1 {
+		t.Errorf("got %d zone list requests for parallel lookups on a cold cache, want 1", got)
+	}
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // Strategy represents the deletion strategy
@@ -16,12 +23,50 @@ const (
 	StrategyUpsertOnly Strategy = "upsert-only"
 )
 
+// Registry represents where DNS record ownership is stored
+type Registry string
+
+const (
+	// RegistryComment stores record ownership in each DNS record's comment
+	RegistryComment Registry = "comment"
+	// RegistryTXT stores record ownership in a companion TXT record for each DNS record set
+	RegistryTXT Registry = "txt"
+)
+
+const (
+	// finalizerPrefix is the name of the finalizer of the default record owner ID, and the start of every other owner ID's finalizer
+	finalizerPrefix = "routeflare.io/dns-records"
+	// maxFinalizerOwnerIDLength is how much of a sanitized owner ID fits in the 63 character name part of a finalizer,
+	// along with a dash and an 8 character hash
+	maxFinalizerOwnerIDLength = 63 - len("dns-records-") - 9
+)
+
+// invalidFinalizerChars matches the characters that aren't allowed in the name part of a finalizer
+var invalidFinalizerChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// LegacyAnnotationPrefix is the prefix of routeflare's annotations before the prefix was configurable
+const LegacyAnnotationPrefix = "routeflare/"
+
+// DefaultRecordOwnerID is the record owner ID used when RECORD_OWNER_ID isn't set
+const DefaultRecordOwnerID = "routeflare"
+
 // Config holds the application configuration
 type Config struct {
 	CloudflareAPIToken string
 	Strategy           Strategy
+	Registry           Registry
 	KubeconfigPath     string
 	RecordOwnerID      string
+	ManagedZones       []string
+	GCInterval         time.Duration
+	DryRun             bool
+	Namespaces         []string
+	ExcludeNamespaces  []string
+	NamespaceSelector  string
+	LabelSelector      string
+	AnnotationPrefix   string
+	LegacyAnnotations  bool
+	StatusAnnotation   bool
 }
 
 // Load loads configuration from environment variables
@@ -45,18 +90,140 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// REGISTRY is optional, defaults to "comment"
+	registryStr := strings.ToLower(os.Getenv("REGISTRY"))
+	if registryStr == "" {
+		cfg.Registry = RegistryComment
+	} else {
+		cfg.Registry = Registry(registryStr)
+		if cfg.Registry != RegistryComment && cfg.Registry != RegistryTXT {
+			return nil, fmt.Errorf("REGISTRY must be either 'comment' or 'txt', got: %s", registryStr)
+		}
+	}
+
 	// KUBECONFIG is optional
 	cfg.KubeconfigPath = os.Getenv("KUBECONFIG")
 
 	// RECORD_OWNER_ID is optional, defaults to "routeflare"
 	cfg.RecordOwnerID = os.Getenv("RECORD_OWNER_ID")
 	if cfg.RecordOwnerID == "" {
-		cfg.RecordOwnerID = "routeflare"
+		cfg.RecordOwnerID = DefaultRecordOwnerID
+	}
+
+	// MANAGED_ZONES is optional, a comma separated list of zone names or IDs to collect orphaned records from
+	cfg.ManagedZones = splitList(os.Getenv("MANAGED_ZONES"))
+
+	// GC_INTERVAL is optional, defaults to "1h"
+	gcIntervalStr := os.Getenv("GC_INTERVAL")
+	if gcIntervalStr == "" {
+		cfg.GCInterval = time.Hour
+	} else {
+		gcInterval, err := time.ParseDuration(gcIntervalStr)
+		if err != nil || gcInterval <= 0 {
+			return nil, fmt.Errorf("GC_INTERVAL must be a positive duration, got: %s", gcIntervalStr)
+		}
+		cfg.GCInterval = gcInterval
+	}
+
+	// DRY_RUN is optional, defaults to false
+	dryRunStr := os.Getenv("DRY_RUN")
+	if dryRunStr != "" {
+		dryRun, err := strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return nil, fmt.Errorf("DRY_RUN must be either 'true' or 'false', got: %s", dryRunStr)
+		}
+		cfg.DryRun = dryRun
+	}
+
+	// NAMESPACES and EXCLUDE_NAMESPACES are optional, comma separated lists of namespaces to watch or not watch
+	cfg.Namespaces = splitList(os.Getenv("NAMESPACES"))
+	cfg.ExcludeNamespaces = splitList(os.Getenv("EXCLUDE_NAMESPACES"))
+	if len(cfg.Namespaces) > 0 && len(cfg.ExcludeNamespaces) > 0 {
+		return nil, fmt.Errorf("only one of NAMESPACES or EXCLUDE_NAMESPACES may be set")
+	}
+
+	// NAMESPACE_SELECTOR is optional, a label selector for the namespaces to watch
+	cfg.NamespaceSelector = os.Getenv("NAMESPACE_SELECTOR")
+	if _, err := labels.Parse(cfg.NamespaceSelector); err != nil {
+		return nil, fmt.Errorf("NAMESPACE_SELECTOR must be a valid label selector, got: %s: %w", cfg.NamespaceSelector, err)
+	}
+
+	// LABEL_SELECTOR is optional, a label selector for the routes, Gateways, Services, and Ingresses to watch
+	cfg.LabelSelector = os.Getenv("LABEL_SELECTOR")
+	if _, err := labels.Parse(cfg.LabelSelector); err != nil {
+		return nil, fmt.Errorf("LABEL_SELECTOR must be a valid label selector, got: %s: %w", cfg.LabelSelector, err)
+	}
+
+	// ANNOTATION_PREFIX is optional, defaults to "routeflare/"
+	cfg.AnnotationPrefix = os.Getenv("ANNOTATION_PREFIX")
+	if cfg.AnnotationPrefix == "" {
+		cfg.AnnotationPrefix = LegacyAnnotationPrefix
+	}
+	cfg.AnnotationPrefix = strings.TrimSuffix(cfg.AnnotationPrefix, "/") + "/"
+	if errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(cfg.AnnotationPrefix, "/")); len(errs) > 0 {
+		return nil, fmt.Errorf("ANNOTATION_PREFIX must be a DNS subdomain, got: %s: %s", cfg.AnnotationPrefix, strings.Join(errs, ", "))
+	}
+
+	// LEGACY_ANNOTATIONS is optional, defaults to true
+	// Annotations with the legacy "routeflare/" prefix are read along with ANNOTATION_PREFIX, so sources can be migrated gradually
+	cfg.LegacyAnnotations = true
+	legacyAnnotationsStr := os.Getenv("LEGACY_ANNOTATIONS")
+	if legacyAnnotationsStr != "" {
+		legacyAnnotations, err := strconv.ParseBool(legacyAnnotationsStr)
+		if err != nil {
+			return nil, fmt.Errorf("LEGACY_ANNOTATIONS must be either 'true' or 'false', got: %s", legacyAnnotationsStr)
+		}
+		cfg.LegacyAnnotations = legacyAnnotations
+	}
+
+	// STATUS_ANNOTATION is optional, defaults to true
+	cfg.StatusAnnotation = true
+	statusAnnotationStr := os.Getenv("STATUS_ANNOTATION")
+	if statusAnnotationStr != "" {
+		statusAnnotation, err := strconv.ParseBool(statusAnnotationStr)
+		if err != nil {
+			return nil, fmt.Errorf("STATUS_ANNOTATION must be either 'true' or 'false', got: %s", statusAnnotationStr)
+		}
+		cfg.StatusAnnotation = statusAnnotation
 	}
 
 	return cfg, nil
 }
 
+// splitList splits a comma separated list, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IsScoped returns true if only some of the cluster's sources are watched, because of a namespace list or a selector
+func (c *Config) IsScoped() bool {
+	return len(c.Namespaces) > 0 || len(c.ExcludeNamespaces) > 0 || c.NamespaceSelector != "" || c.LabelSelector != ""
+}
+
+// FinalizerName returns the finalizer routeflare adds to the sources it manages
+// Each record owner ID has its own finalizer, so instances sharing a cluster don't remove each other's
+func (c *Config) FinalizerName() string {
+	if c.RecordOwnerID == DefaultRecordOwnerID {
+		return finalizerPrefix
+	}
+
+	name := finalizerPrefix + "-" + c.RecordOwnerID
+	if len(validation.IsQualifiedName(name)) == 0 {
+		return name
+	}
+
+	// An owner ID that can't be used as is gets a sanitized and truncated copy, with a short hash of the owner ID to keep it unique
+	sanitized := invalidFinalizerChars.ReplaceAllString(c.RecordOwnerID, "-")
+	sum := sha256.Sum256([]byte(c.RecordOwnerID))
+	return fmt.Sprintf("%s-%.*s-%x", finalizerPrefix, maxFinalizerOwnerIDLength, sanitized, sum[:4])
+}
+
 // ShouldDelete returns true if records should be deleted (full strategy)
 func (c *Config) ShouldDelete() bool {
 	return c.Strategy == StrategyFull
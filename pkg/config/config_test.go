@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestFinalizerName(t *testing.T) {
+	tests := []struct {
+		recordOwnerID string
+		want          string
+	}{
+		{recordOwnerID: DefaultRecordOwnerID, want: "routeflare.io/dns-records"},
+		{recordOwnerID: "staging", want: "routeflare.io/dns-records-staging"},
+		{recordOwnerID: "team_a.prod", want: "routeflare.io/dns-records-team_a.prod"},
+		{recordOwnerID: "cluster/staging", want: "routeflare.io/dns-records-cluster-staging-"},
+		{recordOwnerID: "staging-", want: "routeflare.io/dns-records-staging--"},
+		{recordOwnerID: strings.Repeat("a", 60), want: "routeflare.io/dns-records-" + strings.Repeat("a", maxFinalizerOwnerIDLength) + "-"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{RecordOwnerID: tt.recordOwnerID}
+		got := cfg.FinalizerName()
+		if errs := validation.IsQualifiedName(got); len(errs) > 0 {
+			t.Errorf("FinalizerName() for owner ID %q = %q, which is invalid: %v", tt.recordOwnerID, got, errs)
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("FinalizerName() for owner ID %q = %q, want it to start with %q", tt.recordOwnerID, got, tt.want)
+		}
+	}
+
+	// Owner IDs that sanitize to the same name still get different finalizers
+	first := (&Config{RecordOwnerID: "cluster/staging"}).FinalizerName()
+	second := (&Config{RecordOwnerID: "cluster:staging"}).FinalizerName()
+	if first == second {
+		t.Errorf("owner IDs cluster/staging and cluster:staging share the finalizer %q", first)
+	}
+}
@@ -10,19 +10,22 @@ import (
 	"time"
 
 	"github.com/chia-network/go-modules/pkg/slogs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
 
-	"github.com/starttoaster/routeflare/pkg/cloudflare"
 	"github.com/starttoaster/routeflare/pkg/config"
 	"github.com/starttoaster/routeflare/pkg/ddns"
 	"github.com/starttoaster/routeflare/pkg/kubernetes"
+	"github.com/starttoaster/routeflare/pkg/provider"
 )
 
-// Controller manages HTTPRoute informer and DNS record management
+// Controller manages route, Gateway, Service, Ingress, and RouteflareRecord informers and DNS record management
 type Controller struct {
 	cfg               *config.Config
 	k8sClient         *kubernetes.Client
-	cfClient          *cloudflare.Client
+	dnsProvider       provider.Provider
 	ddnsDetector      *ddns.Detector
+	eventRecorder     record.EventRecorder
 	ctx               context.Context
 	cancel            context.CancelFunc
 	trackedRoutes     map[string]*trackedRoute
@@ -32,7 +35,8 @@ type Controller struct {
 }
 
 type trackedRoute struct {
-	contentMode string // "gateway-address" or "ddns"
+	contentMode string // "gateway-address", "load-balancer-address", "static", or "ddns"
+	kind        string // "HTTPRoute", "GRPCRoute", "TLSRoute", "Gateway", "Service", "Ingress", or "RouteflareRecord"
 	namespace   string
 	name        string
 	zoneName    string
@@ -41,19 +45,26 @@ type trackedRoute struct {
 	ttl         int
 	proxied     bool
 	lastIPs     []string
+	cnameTarget string // Set when a CNAME was published instead of A/AAAA records
 	// Gateway-specific fields (only used for gateway-address mode)
 	gatewayNamespace string
 	gatewayName      string
 }
 
+// sourceKey returns the key of the source a tracked record belongs to
+func (r *trackedRoute) sourceKey() string {
+	return fmt.Sprintf("%s/%s/%s", r.kind, r.namespace, r.name)
+}
+
 // NewController creates a new controller
-func NewController(cfg *config.Config, k8sClient *kubernetes.Client, cfClient *cloudflare.Client) *Controller {
+func NewController(cfg *config.Config, k8sClient *kubernetes.Client, dnsProvider provider.Provider) *Controller {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Controller{
 		cfg:               cfg,
 		k8sClient:         k8sClient,
-		cfClient:          cfClient,
+		dnsProvider:       dnsProvider,
 		ddnsDetector:      ddns.NewDetector(),
+		eventRecorder:     k8sClient.GetEventRecorder(),
 		ctx:               ctx,
 		cancel:            cancel,
 		trackedRoutes:     make(map[string]*trackedRoute),
@@ -73,11 +84,14 @@ func (c *Controller) Run() error {
 	// Start reconciliation background job
 	go c.runReconciliationJob()
 
-	// Start HTTPRoute informer
-	if err := c.startHTTPRouteInformer(); err != nil {
-		return fmt.Errorf("error starting HTTPRoute informer: %w", err)
+	// Start route and Gateway informers
+	if err := c.startInformers(); err != nil {
+		return fmt.Errorf("error starting informers: %w", err)
 	}
 
+	// Start garbage collection background job, once the informer caches have every source's claim
+	go c.runGarbageCollectionJob()
+
 	// Block until context is cancelled
 	<-c.ctx.Done()
 	slogs.Logr.Info("Controller shutting down")
@@ -131,25 +145,45 @@ func (c *Controller) healthcheckHandler(w http.ResponseWriter, _ *http.Request)
 // Helper funcs
 
 // extractRouteflareAnnotations gathers all routeflare-related settings from annotations
-func extractRouteflareAnnotations(annotations map[string]string) map[string]string {
-	const annotationPrefix = "routeflare/"
+// Settings under the configured annotation prefix take precedence over the same settings under the legacy prefix
+func (c *Controller) extractRouteflareAnnotations(annotations map[string]string) map[string]string {
 	result := make(map[string]string)
+	if c.cfg.LegacyAnnotations && c.cfg.AnnotationPrefix != config.LegacyAnnotationPrefix {
+		addPrefixedAnnotations(result, annotations, config.LegacyAnnotationPrefix)
+	}
+	addPrefixedAnnotations(result, annotations, c.cfg.AnnotationPrefix)
+	return result
+}
+
+// addPrefixedAnnotations adds the settings in annotations with a prefix to settings, keyed by their names without the prefix
+func addPrefixedAnnotations(settings, annotations map[string]string, annotationPrefix string) {
 	for key, value := range annotations {
 		if strings.HasPrefix(key, annotationPrefix) {
 			settingName := strings.TrimPrefix(key, annotationPrefix)
-			result[settingName] = value
+			settings[settingName] = value
 		}
 	}
-	return result
 }
 
-// extractZoneFromRecordName gets the zone name from a domain (eg. "domain.tld" from "arbitrary.subdomain.levels.domain.tld")
-func extractZoneFromRecordName(recordName string) (string, error) {
-	parts := strings.Split(recordName, ".")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid record name format: %s", recordName)
+// recordSource describes a Kubernetes resource as the source of the DNS records published for it
+func recordSource(obj *unstructured.Unstructured, contentMode string) provider.Source {
+	return provider.Source{
+		Kind:        obj.GetKind(),
+		Namespace:   obj.GetNamespace(),
+		Name:        obj.GetName(),
+		UID:         string(obj.GetUID()),
+		ContentMode: contentMode,
 	}
-	return strings.Join(parts[len(parts)-2:], "."), nil
+}
+
+// isZoneApex returns true if the record name is the name of the zone itself
+func isZoneApex(recordName, zoneName string) bool {
+	return strings.EqualFold(strings.TrimSuffix(recordName, "."), strings.TrimSuffix(zoneName, "."))
+}
+
+// isApexWildcard returns true if the record name is a wildcard directly under the zone apex, such as "*.example.com" in the zone "example.com"
+func isApexWildcard(recordName, zoneName string) bool {
+	return provider.IsWildcard(recordName) && isZoneApex(strings.TrimPrefix(recordName, "*."), zoneName)
 }
 
 // isIPv6 returns true if input is an IPv6 address
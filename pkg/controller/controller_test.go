@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/starttoaster/routeflare/pkg/config"
+)
+
+func TestExtractRouteflareAnnotationsPrefixPrecedence(t *testing.T) {
+	tests := []struct {
+		name              string
+		annotationPrefix  string
+		legacyAnnotations bool
+		annotations       map[string]string
+		want              map[string]string
+	}{
+		{
+			name:              "configured prefix wins over the legacy prefix",
+			annotationPrefix:  "routeflare.io/",
+			legacyAnnotations: true,
+			annotations:       map[string]string{"routeflare.io/type": "AAAA", "routeflare/type": "A"},
+			want:              map[string]string{"type": "AAAA"},
+		},
+		{
+			name:              "legacy prefix fills in settings missing from the configured prefix",
+			annotationPrefix:  "routeflare.io/",
+			legacyAnnotations: true,
+			annotations:       map[string]string{"routeflare.io/type": "AAAA", "routeflare/content-mode": "ddns", "routeflare/ttl": "120"},
+			want:              map[string]string{"type": "AAAA", "content-mode": "ddns", "ttl": "120"},
+		},
+		{
+			name:              "legacy prefix ignored when disabled",
+			annotationPrefix:  "routeflare.io/",
+			legacyAnnotations: false,
+			annotations:       map[string]string{"routeflare.io/type": "AAAA", "routeflare/content-mode": "ddns"},
+			want:              map[string]string{"type": "AAAA"},
+		},
+		{
+			name:              "legacy prefix configured",
+			annotationPrefix:  config.LegacyAnnotationPrefix,
+			legacyAnnotations: true,
+			annotations:       map[string]string{"routeflare/type": "A", "routeflare.io/type": "AAAA"},
+			want:              map[string]string{"type": "A"},
+		},
+		{
+			name:              "other annotations ignored",
+			annotationPrefix:  "dns.example.com/",
+			legacyAnnotations: true,
+			annotations:       map[string]string{"example.com/type": "AAAA", "dns.example.com/type": "A", "routeflare.io/ttl": "60"},
+			want:              map[string]string{"type": "A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _, _ := newInMemoryTestController(t)
+			c.cfg.AnnotationPrefix = tt.annotationPrefix
+			c.cfg.LegacyAnnotations = tt.legacyAnnotations
+
+			if got := c.extractRouteflareAnnotations(tt.annotations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractRouteflareAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Reasons of the Kubernetes Events recorded on sources
+const (
+	reasonRecordCreated     = "RecordCreated"
+	reasonRecordUpdated     = "RecordUpdated"
+	reasonRecordDeleted     = "RecordDeleted"
+	reasonOwnershipConflict = "OwnershipConflict"
+	reasonPublishFailed     = "PublishFailed"
+)
+
+// recordEvent records a Kubernetes Event on a source, so the people who own it can see what happened to its DNS records
+// Nothing is recorded without a source, such as when deleting orphaned records
+func (c *Controller) recordEvent(obj *unstructured.Unstructured, eventType, reason, message string) {
+	if obj == nil {
+		return
+	}
+	if c.cfg.DryRun {
+		message = "[dry run] " + message
+	}
+	c.eventRecorder.Event(obj, eventType, reason, message)
+}
+
+// recordChangeEvents records an Event on a source for each of its DNS records that was created, updated, or deleted
+func (c *Controller) recordChangeEvents(obj *unstructured.Unstructured, changes provider.RecordSetChanges) {
+	for _, record := range changes.Create {
+		c.recordEvent(obj, corev1.EventTypeNormal, reasonRecordCreated,
+			fmt.Sprintf("Created %s record %s with content %s", record.Type, record.Name, record.Content))
+	}
+	for _, record := range changes.Update {
+		c.recordEvent(obj, corev1.EventTypeNormal, reasonRecordUpdated,
+			fmt.Sprintf("Updated %s record %s with content %s", record.Type, record.Name, record.Content))
+	}
+	for _, record := range changes.Delete {
+		c.recordEvent(obj, corev1.EventTypeNormal, reasonRecordDeleted,
+			fmt.Sprintf("Deleted %s record %s with content %s", record.Type, record.Name, record.Content))
+	}
+}
+
+// recordErrorEvent records a Warning Event on a source for an error publishing or deleting its DNS records
+func (c *Controller) recordErrorEvent(obj *unstructured.Unstructured, err error) {
+	reason := reasonPublishFailed
+	if isOwnershipConflict(err) {
+		reason = reasonOwnershipConflict
+	}
+	c.recordEvent(obj, corev1.EventTypeWarning, reason, strings.ReplaceAll(err.Error(), "\n", "; "))
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"slices"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// usesFinalizer returns true if routeflare adds its finalizer to the sources it manages, so it can delete their records
+// before they are deleted, even if it wasn't running when they were
+// Records are only deleted with the full strategy, and dry run mode doesn't change sources
+func (c *Controller) usesFinalizer() bool {
+	return c.cfg.ShouldDelete() && !c.cfg.DryRun
+}
+
+// hasFinalizer returns true if a source has this instance's finalizer
+func (c *Controller) hasFinalizer(obj *unstructured.Unstructured) bool {
+	return slices.Contains(obj.GetFinalizers(), c.cfg.FinalizerName())
+}
+
+// addFinalizer adds this instance's finalizer to a source, if it doesn't already have it
+func (c *Controller) addFinalizer(obj *unstructured.Unstructured) {
+	if c.hasFinalizer(obj) {
+		return
+	}
+
+	finalizers := append(slices.Clone(obj.GetFinalizers()), c.cfg.FinalizerName())
+	if err := c.k8sClient.SetSourceFinalizers(c.ctx, obj, finalizers); err != nil {
+		slogs.Logr.Error("adding finalizer", "source", objectKey(obj), "error", err)
+	}
+}
+
+// removeFinalizer removes this instance's finalizer from a source, if it has it, leaving any other finalizers in place
+// Dry run mode doesn't change sources, so the finalizer is left for the instance that added it
+func (c *Controller) removeFinalizer(obj *unstructured.Unstructured) {
+	if c.cfg.DryRun || !c.hasFinalizer(obj) {
+		return
+	}
+
+	finalizers := slices.DeleteFunc(slices.Clone(obj.GetFinalizers()), func(finalizer string) bool {
+		return finalizer == c.cfg.FinalizerName()
+	})
+	if err := c.k8sClient.SetSourceFinalizers(c.ctx, obj, finalizers); err != nil {
+		slogs.Logr.Error("removing finalizer", "source", objectKey(obj), "error", err)
+	}
+}
+
+// finalizeSource deletes the records of a source that is being deleted, and then removes this instance's finalizer so the deletion can finish
+// The finalizer is kept if a record couldn't be deleted, and deleting it is retried by the reconciliation job
+func (c *Controller) finalizeSource(obj *unstructured.Unstructured) {
+	if !c.hasFinalizer(obj) {
+		return // The records are deleted when the source is
+	}
+
+	slogs.Logr.Info("Finalizing source", "source", objectKey(obj))
+	if err := c.processSourceDeletion(obj); err != nil {
+		slogs.Logr.Error("deleting records of source being deleted, keeping finalizer",
+			"source", objectKey(obj),
+			"error", err)
+		c.recordErrorEvent(obj, err)
+		return
+	}
+	c.removeFinalizer(obj)
+}
+
+// releaseSource removes this instance's finalizer from a source that left the scope of its watches, so it wouldn't see the source being deleted
+func (c *Controller) releaseSource(obj *unstructured.Unstructured) {
+	if c.cfg.DryRun || !c.hasFinalizer(obj) {
+		return
+	}
+
+	// The source may have changed since it was last seen, such as by having its labels changed
+	current, err := c.k8sClient.GetSource(c.ctx, obj)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		slogs.Logr.Error("getting source to remove finalizer", "source", objectKey(obj), "error", err)
+		return
+	}
+	c.removeFinalizer(current)
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/starttoaster/routeflare/pkg/config"
+)
+
+func TestFinalizerIsPerInstance(t *testing.T) {
+	c, _, _, _ := newTestController(t)
+	other := &config.Config{RecordOwnerID: "staging", Strategy: config.StrategyFull}
+
+	route := testRoute()
+	route.SetFinalizers([]string{other.FinalizerName()})
+	if c.hasFinalizer(route) {
+		t.Errorf("finalizer %s of another instance is treated as %s", other.FinalizerName(), c.cfg.FinalizerName())
+	}
+
+	// Only changes to this instance's finalizer are routeflare's own metadata updates
+	updated := route.DeepCopy()
+	updated.SetFinalizers([]string{other.FinalizerName(), c.cfg.FinalizerName()})
+	if !c.isRouteflareMetadataUpdate(route, updated) {
+		t.Error("adding this instance's finalizer isn't a routeflare metadata update")
+	}
+	if c.isRouteflareMetadataUpdate(testRoute(), route) {
+		t.Error("adding another instance's finalizer is a routeflare metadata update")
+	}
+
+	// Dry run mode never patches sources, so removing the finalizer is skipped without a Kubernetes client
+	c.cfg.DryRun = true
+	c.removeFinalizer(updated)
+	c.releaseSource(updated)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// gatewayAddressEventHandler returns event handlers for Gateways in every namespace, which re-publish the records of the routes
+// attached to a Gateway when its addresses change, since routes in scope may be attached to Gateways outside of it
+func (c *Controller) gatewayAddressEventHandler() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldGateway, ok := oldObj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			newGateway, ok := newObj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			if gatewayAddressesEqual(oldGateway, newGateway) {
+				return
+			}
+
+			slogs.Logr.Info("Gateway addresses changed, re-publishing its routes",
+				"gateway", fmt.Sprintf("%s/%s", newGateway.GetNamespace(), newGateway.GetName()))
+			c.processGatewaySources(newGateway)
+		},
+	}
+}
+
+// processGatewaySources re-publishes the records of the gateway-address routes attached to a Gateway,
+// and the RouteflareRecords referencing it
+func (c *Controller) processGatewaySources(gatewayObj *unstructured.Unstructured) {
+	sources, err := c.k8sClient.ListSourcesForGateway(gatewayObj.GetNamespace(), gatewayObj.GetName())
+	if err != nil {
+		slogs.Logr.Error("listing sources for Gateway",
+			"gateway", fmt.Sprintf("%s/%s", gatewayObj.GetNamespace(), gatewayObj.GetName()),
+			"error", err)
+		return
+	}
+
+	for _, source := range sources {
+		if opts, ok := c.parseRecordOptions(source); ok && opts.contentMode == "gateway-address" {
+			c.processSource(source, false)
+		}
+	}
+}
+
+// gatewayAddressesEqual returns true if two versions of a Gateway have the same status.addresses
+func gatewayAddressesEqual(oldGateway, newGateway *unstructured.Unstructured) bool {
+	oldAddresses, _, _ := unstructured.NestedSlice(oldGateway.Object, "status", "addresses")
+	newAddresses, _, _ := unstructured.NestedSlice(newGateway.Object, "status", "addresses")
+	return reflect.DeepEqual(oldAddresses, newAddresses)
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/starttoaster/routeflare/pkg/config"
+	"github.com/starttoaster/routeflare/pkg/provider"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// runGarbageCollectionJob deletes orphaned records in the managed zones at startup, and then on an interval
+// Orphaned records are ones owned by this instance that no route claims, such as records left behind
+// by a route deleted while routeflare wasn't running
+func (c *Controller) runGarbageCollectionJob() {
+	if !c.shouldCollectGarbage() {
+		return
+	}
+
+	c.collectGarbage(c.listSources)
+
+	ticker := time.NewTicker(c.cfg.GCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.collectGarbage(c.listSources)
+		}
+	}
+}
+
+// shouldCollectGarbage returns true if orphaned records can be collected with this instance's configuration
+func (c *Controller) shouldCollectGarbage() bool {
+	if len(c.cfg.ManagedZones) == 0 {
+		return false // No managed zones configured, nothing to collect
+	}
+	if !c.cfg.ShouldDelete() {
+		slogs.Logr.Info("Skipping garbage collection of orphaned records with upsert-only strategy")
+		return false
+	}
+	// A scoped instance only sees some of the sources, so with a shared owner ID it would delete the records of other instances
+	if c.cfg.IsScoped() && c.cfg.RecordOwnerID == config.DefaultRecordOwnerID {
+		slogs.Logr.Error("Refusing to collect orphaned records: a namespace list or selector is set, but RECORD_OWNER_ID is left at the default, "+
+			"so records made by other instances of routeflare would be deleted. Set RECORD_OWNER_ID to an ID unique to this instance.",
+			"recordOwnerID", c.cfg.RecordOwnerID)
+		return false
+	}
+	return true
+}
+
+// collectGarbage deletes orphaned records in each of the managed zones, which are the ones no listed source claims
+func (c *Controller) collectGarbage(listSources func() []*unstructured.Unstructured) {
+	for _, managedZone := range c.cfg.ManagedZones {
+		// Managed zones may be given by name or by ID, anything that isn't a known zone name is treated as an ID
+		zoneID, err := c.dnsProvider.GetZoneIDByName(managedZone)
+		if err != nil {
+			zoneID = managedZone
+		}
+
+		if err := c.collectZoneGarbage(zoneID, listSources); err != nil {
+			slogs.Logr.Error("collecting orphaned records", "zone", managedZone, "error", err)
+		}
+	}
+}
+
+// collectZoneGarbage deletes orphaned records in a zone
+func (c *Controller) collectZoneGarbage(zoneID string, listSources func() []*unstructured.Unstructured) error {
+	records, err := c.dnsProvider.ListRecords(c.ctx, zoneID)
+	if err != nil {
+		return err
+	}
+
+	// Claims are gathered after listing records, so a record created by a newly added route
+	// is always claimed by it, because the informer cache is updated before its handlers run
+	claimed := c.claimedRecordSets(listSources())
+
+	var errs []error
+	deleted := make(map[string]bool)
+	for _, record := range records {
+		if record.OwnerID != c.cfg.RecordOwnerID {
+			continue
+		}
+		if record.Type != provider.RecordTypeA && record.Type != provider.RecordTypeAAAA && record.Type != provider.RecordTypeCNAME {
+			continue
+		}
+		// DeleteRecord removes every record with the name and type, so each record set is only deleted once
+		setKey := recordSetKey(record.Type, record.Name)
+		if claimed[setKey] {
+			continue
+		}
+		if deleted[setKey] {
+			continue
+		}
+		deleted[setKey] = true
+
+		slogs.Logr.Info("Deleting orphaned record", "type", record.Type, "name", record.Name)
+		if err := c.deleteRecords(nil, zoneID, record.Name, []provider.RecordType{record.Type}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// claimedRecordSets returns the keys of the record sets of every source with routeflare annotations,
+// which are the record types a source may publish for each of its record names
+func (c *Controller) claimedRecordSets(sources []*unstructured.Unstructured) map[string]bool {
+	claimed := make(map[string]bool)
+	for _, obj := range sources {
+		opts, ok := c.parseRecordOptions(obj)
+		if !ok {
+			continue
+		}
+
+		recordNames, err := c.getRecordNames(obj)
+		if err != nil {
+			continue
+		}
+		for _, recordName := range recordNames {
+			for _, recordType := range sourceRecordTypes(opts.recordType, opts.contentMode) {
+				claimed[recordSetKey(recordType, recordName)] = true
+			}
+		}
+	}
+	return claimed
+}
+
+// recordSetKey returns the key of the record set with a type and name
+func recordSetKey(recordType provider.RecordType, recordName string) string {
+	return fmt.Sprintf("%s/%s", recordType, normalizeRecordName(recordName))
+}
+
+// normalizeRecordName lowercases a record name and strips any trailing dot
+func normalizeRecordName(recordName string) string {
+	return strings.TrimSuffix(strings.ToLower(recordName), ".")
+}
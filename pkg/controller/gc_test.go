@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/starttoaster/routeflare/pkg/config"
+	"github.com/starttoaster/routeflare/pkg/provider"
+	"github.com/starttoaster/routeflare/pkg/provider/inmemory"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// annotatedRoute returns an HTTPRoute publishing A records for a hostname with gateway-address content mode
+func annotatedRoute(hostname string) *unstructured.Unstructured {
+	route := testRoute()
+	route.SetAnnotations(map[string]string{"routeflare/content-mode": "gateway-address", "routeflare/type": "A"})
+	_ = unstructured.SetNestedStringSlice(route.Object, []string{hostname}, "spec", "hostnames")
+	return route
+}
+
+// addRecord adds a record to a zone of the in-memory provider
+func addRecord(t *testing.T, dnsProvider *inmemory.Provider, zoneID string, recordType provider.RecordType, name, content, ownerID string) {
+	t.Helper()
+
+	record := provider.Record{Type: recordType, Name: name, Content: content, TTL: 1, OwnerID: ownerID}
+	if _, err := dnsProvider.UpsertRecord(context.Background(), zoneID, record); err != nil {
+		t.Fatalf("adding record %s %s: %v", recordType, name, err)
+	}
+}
+
+// recordNames returns the type and name of each record in a zone of the in-memory provider, sorted
+func recordNames(t *testing.T, dnsProvider *inmemory.Provider, zoneID string) []string {
+	t.Helper()
+
+	records, err := dnsProvider.ListRecords(context.Background(), zoneID)
+	if err != nil {
+		t.Fatalf("listing records: %v", err)
+	}
+	var names []string
+	for _, record := range records {
+		names = append(names, string(record.Type)+" "+record.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCollectZoneGarbage(t *testing.T) {
+	c, dnsProvider, zoneID, _ := newInMemoryTestController(t)
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "claimed.example.com", "192.0.2.1", "routeflare")
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "orphaned.example.com", "192.0.2.2", "routeflare")
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeCNAME, "orphaned-cname.example.com", "lb.example.net", "routeflare")
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "other.example.com", "192.0.2.3", "someone-else")
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "unowned.example.com", "192.0.2.4", "")
+
+	sources := func() []*unstructured.Unstructured {
+		return []*unstructured.Unstructured{annotatedRoute("Claimed.example.com")}
+	}
+	if err := c.collectZoneGarbage(zoneID, sources); err != nil {
+		t.Fatalf("collectZoneGarbage: %v", err)
+	}
+
+	// Only records owned by this instance that no source claims are deleted
+	want := []string{"A claimed.example.com", "A other.example.com", "A unowned.example.com"}
+	if got := recordNames(t, dnsProvider, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+}
+
+func TestCollectZoneGarbageClaimsSourceRecordTypes(t *testing.T) {
+	c, dnsProvider, zoneID, _ := newInMemoryTestController(t)
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "app.example.com", "192.0.2.1", "routeflare")
+	addRecord(t, dnsProvider, zoneID, provider.RecordTypeAAAA, "app.example.com", "2001:db8::1", "routeflare")
+
+	// A gateway-address source with A records may also publish a CNAME, but never AAAA records
+	sources := func() []*unstructured.Unstructured {
+		return []*unstructured.Unstructured{annotatedRoute("app.example.com")}
+	}
+	if err := c.collectZoneGarbage(zoneID, sources); err != nil {
+		t.Fatalf("collectZoneGarbage: %v", err)
+	}
+
+	want := []string{"A app.example.com"}
+	if got := recordNames(t, dnsProvider, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+}
+
+func TestCollectGarbageManagedZoneByNameOrID(t *testing.T) {
+	for _, byName := range []bool{true, false} {
+		c, dnsProvider, zoneID, _ := newInMemoryTestController(t)
+		c.cfg.ManagedZones = []string{zoneID}
+		if byName {
+			c.cfg.ManagedZones = []string{"Example.com"}
+		}
+		addRecord(t, dnsProvider, zoneID, provider.RecordTypeA, "orphaned.example.com", "192.0.2.1", "routeflare")
+
+		c.collectGarbage(func() []*unstructured.Unstructured { return nil })
+
+		if got := recordNames(t, dnsProvider, zoneID); len(got) != 0 {
+			t.Errorf("managed zone %q: got records %v, want none", c.cfg.ManagedZones[0], got)
+		}
+	}
+}
+
+func TestShouldCollectGarbage(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want bool
+	}{
+		{
+			name: "full strategy",
+			cfg:  config.Config{ManagedZones: []string{"example.com"}, Strategy: config.StrategyFull, RecordOwnerID: config.DefaultRecordOwnerID},
+			want: true,
+		},
+		{
+			name: "no managed zones",
+			cfg:  config.Config{Strategy: config.StrategyFull, RecordOwnerID: config.DefaultRecordOwnerID},
+		},
+		{
+			name: "upsert-only skips deletes",
+			cfg:  config.Config{ManagedZones: []string{"example.com"}, Strategy: config.StrategyUpsertOnly, RecordOwnerID: config.DefaultRecordOwnerID},
+		},
+		{
+			name: "scoped with the default owner ID",
+			cfg:  config.Config{ManagedZones: []string{"example.com"}, Strategy: config.StrategyFull, RecordOwnerID: config.DefaultRecordOwnerID, Namespaces: []string{"team-a"}},
+		},
+		{
+			name: "scoped with its own owner ID",
+			cfg:  config.Config{ManagedZones: []string{"example.com"}, Strategy: config.StrategyFull, RecordOwnerID: "team-a", Namespaces: []string{"team-a"}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _, _ := newInMemoryTestController(t)
+			c.cfg = &tt.cfg
+			if got := c.shouldCollectGarbage(); got != tt.want {
+				t.Errorf("shouldCollectGarbage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
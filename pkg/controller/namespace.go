@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceEventHandler returns event handlers for the namespaces matching the namespace selector,
+// which publish the records of a namespace's sources when its labels start matching, and delete them when they stop
+func (c *Controller) namespaceEventHandler() cache.ResourceEventHandlerDetailedFuncs {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			namespace, ok := obj.(*unstructured.Unstructured)
+			if !ok || isInInitialList {
+				return // Sources in the initial namespaces are processed once every cache is synced
+			}
+			if !c.k8sClient.WatchesNamespace(namespace.GetName()) {
+				return // Also excluded by the namespace list
+			}
+
+			slogs.Logr.Info("Namespace entered scope", "namespace", namespace.GetName())
+			for _, source := range c.listNamespaceSources(namespace.GetName()) {
+				c.processSource(source, false)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			namespace, ok := toSource(obj)
+			if !ok {
+				return
+			}
+
+			slogs.Logr.Info("Namespace left scope", "namespace", namespace.GetName())
+			for _, source := range c.listNamespaceSources(namespace.GetName()) {
+				if err := c.processSourceDeletion(source); err != nil {
+					slogs.Logr.Error("deleting records of source out of scope", "source", objectKey(source), "error", err)
+				}
+				c.releaseSource(source)
+			}
+		},
+	}
+}
+
+// listNamespaceSources lists the sources in a namespace in the informer caches, whether the namespace is in scope or not
+func (c *Controller) listNamespaceSources(namespace string) []*unstructured.Unstructured {
+	var sources []*unstructured.Unstructured
+	for _, source := range c.listCachedSources() {
+		if source.GetNamespace() == namespace {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
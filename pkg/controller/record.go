@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/starttoaster/routeflare/pkg/gateway"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// parseRecordSpec parses the settings in a RouteflareRecord's spec, which take the place of a route's routeflare annotations
+// The content mode comes from which content source is set: ddns, static addresses, or a Gateway reference
+func parseRecordSpec(record *unstructured.Unstructured) recordOptions {
+	opts := recordOptions{recordType: "A", ttl: 1} // Default to A records with auto TTL
+	if recordType, _, _ := unstructured.NestedString(record.Object, "spec", "type"); recordType != "" {
+		opts.recordType = recordType
+	}
+	if ttl, found, _ := unstructured.NestedInt64(record.Object, "spec", "ttl"); found && ttl > 0 {
+		opts.ttl = int(ttl)
+	}
+	opts.proxied, _, _ = unstructured.NestedBool(record.Object, "spec", "proxied")
+	opts.allAddresses, _, _ = unstructured.NestedBool(record.Object, "spec", "allAddresses")
+	opts.allowApexWildcard, _, _ = unstructured.NestedBool(record.Object, "spec", "allowApexWildcard")
+
+	source, _, _ := unstructured.NestedMap(record.Object, "spec", "source")
+	switch {
+	case source["ddns"] != nil:
+		opts.contentMode = "ddns"
+	case source["addresses"] != nil:
+		opts.contentMode = "static"
+	case source["gatewayRef"] != nil:
+		opts.contentMode = "gateway-address"
+	}
+	return opts
+}
+
+// getRecordNamesFromRecord gets the hostname of a RouteflareRecord
+func getRecordNamesFromRecord(record *unstructured.Unstructured) ([]string, error) {
+	hostname, _, _ := unstructured.NestedString(record.Object, "spec", "hostname")
+	if hostname == "" {
+		return nil, fmt.Errorf("record has no spec.hostname")
+	}
+	return []string{hostname}, nil
+}
+
+// getRecordGateway returns the Gateway a RouteflareRecord's spec.source.gatewayRef references
+func (c *Controller) getRecordGateway(record *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	ref, found := gateway.GetGatewayRef(record)
+	if !found {
+		return nil, fmt.Errorf("record does not have a spec.source.gatewayRef name")
+	}
+
+	gatewayObj, err := c.k8sClient.GetGateway(ref.Namespace, ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Gateway %s: %w", ref.Key(), err)
+	}
+	if !gateway.HasListener(gatewayObj, ref) {
+		return nil, fmt.Errorf("gateway %s has no listener named %q", ref.Key(), ref.SectionName)
+	}
+	return gatewayObj, nil
+}
+
+// processStaticMode publishes a RouteflareRecord's record pointing at the addresses in its spec.source.addresses
+func (c *Controller) processStaticMode(record *unstructured.Unstructured, recordName string, opts recordOptions) error {
+	addresses, _, _ := unstructured.NestedStringSlice(record.Object, "spec", "source", "addresses")
+	ips, err := gateway.GetStaticAddresses(addresses, opts.recordType)
+	if err != nil {
+		return fmt.Errorf("error getting static addresses: %w", err)
+	}
+
+	return c.publishAddresses(record, recordName, opts, ips, "", &trackedRoute{})
+}
+
+// updateRecordStatus reports the result of publishing a RouteflareRecord in its status's Ready condition
+// The status is only written when the condition changes, and never in dry run mode
+func (c *Controller) updateRecordStatus(record *unstructured.Unstructured, publishErr error) {
+	if c.cfg.DryRun {
+		return
+	}
+
+	condition := map[string]interface{}{
+		"type":               "Ready",
+		"status":             "True",
+		"reason":             "Published",
+		"message":            "DNS records are published",
+		"observedGeneration": record.GetGeneration(),
+	}
+	if publishErr != nil {
+		condition["status"] = "False"
+		condition["reason"] = "PublishFailed"
+		condition["message"] = strings.ReplaceAll(publishErr.Error(), "\n", "; ")
+	}
+
+	// Keep the time of the last transition if the condition's status hasn't changed
+	condition["lastTransitionTime"] = time.Now().UTC().Format(time.RFC3339)
+	conditions, _, _ := unstructured.NestedSlice(record.Object, "status", "conditions")
+	for _, existingInterface := range conditions {
+		existing, ok := existingInterface.(map[string]interface{})
+		if !ok || existing["type"] != condition["type"] {
+			continue
+		}
+		if existing["status"] == condition["status"] {
+			if existing["reason"] == condition["reason"] && existing["message"] == condition["message"] &&
+				existing["observedGeneration"] == condition["observedGeneration"] {
+				return // Unchanged
+			}
+			condition["lastTransitionTime"] = existing["lastTransitionTime"]
+		}
+	}
+
+	status := map[string]interface{}{
+		"conditions":         []interface{}{condition},
+		"observedGeneration": record.GetGeneration(),
+	}
+	if err := c.k8sClient.PatchRecordStatus(c.ctx, record, status); err != nil {
+		slogs.Logr.Error("updating RouteflareRecord status", "source", objectKey(record), "error", err)
+	}
+}
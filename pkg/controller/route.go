@@ -0,0 +1,927 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/starttoaster/routeflare/pkg/gateway"
+	"github.com/starttoaster/routeflare/pkg/kubernetes"
+	"github.com/starttoaster/routeflare/pkg/provider"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sourceEventHandler returns event handlers that publish the DNS records of sources, which are routes, Gateways, Services, Ingresses,
+// and RouteflareRecords, as they change
+// Sources in namespaces that aren't in scope are filtered out, and a source is treated as deleted when its namespace leaves the scope
+func (c *Controller) sourceEventHandler() cache.ResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			source, ok := toSource(obj)
+			return ok && c.k8sClient.WatchesNamespace(source.GetNamespace())
+		},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if source, ok := obj.(*unstructured.Unstructured); ok {
+					slogs.Logr.Info("Source added", "source", objectKey(source))
+					c.processSource(source, false)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				source, ok := newObj.(*unstructured.Unstructured)
+				if !ok {
+					return
+				}
+				// A RouteflareRecord's status is written by routeflare, so only changes to its spec, which bump its generation,
+				// and its deletion are published
+				if old, ok := oldObj.(*unstructured.Unstructured); ok && source.GetKind() == kubernetes.RecordKind &&
+					old.GetGeneration() == source.GetGeneration() && source.GetDeletionTimestamp() == nil {
+					return
+				}
+				// Likewise, a source's status annotation and routeflare's finalizer are written by routeflare
+				if old, ok := oldObj.(*unstructured.Unstructured); ok && c.isRouteflareMetadataUpdate(old, source) {
+					return
+				}
+				slogs.Logr.Info("Source modified", "source", objectKey(source))
+				c.processSource(source, false)
+			},
+			DeleteFunc: func(obj interface{}) {
+				source, ok := toSource(obj)
+				if !ok {
+					slogs.Logr.Warn("Unknown object type in delete handler", "type", fmt.Sprintf("%T", obj))
+					return
+				}
+				slogs.Logr.Info("Source deleted", "source", objectKey(source))
+				if err := c.processSourceDeletion(source); err != nil {
+					slogs.Logr.Error("deleting records of deleted source", "source", objectKey(source), "error", err)
+				}
+				if c.hasFinalizer(source) && source.GetDeletionTimestamp() == nil {
+					// The source wasn't deleted, it left the scope of the watch, such as by its labels no longer matching the label selector
+					c.releaseSource(source)
+				}
+			},
+		},
+	}
+}
+
+// toSource converts an informer object to a source, which for deletes might be a DeletedFinalStateUnknown
+func toSource(obj interface{}) (*unstructured.Unstructured, bool) {
+	switch t := obj.(type) {
+	case *unstructured.Unstructured:
+		return t, true
+	case cache.DeletedFinalStateUnknown:
+		source, ok := t.Obj.(*unstructured.Unstructured)
+		return source, ok
+	default:
+		return nil, false
+	}
+}
+
+// startInformers sets up event handlers on the route, Gateway, Service, and Ingress informers, starts them, and processes existing sources
+func (c *Controller) startInformers() error {
+	for kind, informers := range c.k8sClient.GetSourceInformers() {
+		for _, informer := range informers {
+			if _, err := informer.AddEventHandler(c.sourceEventHandler()); err != nil {
+				return fmt.Errorf("error adding %s event handlers: %w", kind, err)
+			}
+		}
+	}
+	if informer := c.k8sClient.GetGatewayInformer(); informer != nil {
+		if _, err := informer.AddEventHandler(c.gatewayAddressEventHandler()); err != nil {
+			return fmt.Errorf("error adding Gateway address event handlers: %w", err)
+		}
+	}
+	if informer := c.k8sClient.GetNamespaceInformer(); informer != nil {
+		if _, err := informer.AddEventHandler(c.namespaceEventHandler()); err != nil {
+			return fmt.Errorf("error adding namespace event handlers: %w", err)
+		}
+	}
+
+	// Start the informer factory
+	stopCh := make(chan struct{})
+	go func() {
+		<-c.ctx.Done()
+		close(stopCh)
+	}()
+	c.k8sClient.StartInformerFactory(stopCh)
+
+	// Wait for cache to sync
+	slogs.Logr.Info("Waiting for informer caches to sync...", "routeKinds", c.k8sClient.RouteKinds())
+	if !c.k8sClient.WaitForCacheSync(c.ctx) {
+		return fmt.Errorf("error waiting for informer caches to sync")
+	}
+	slogs.Logr.Info("Informer caches synced")
+
+	// Process existing sources from cache
+	sources := c.listSources()
+	slogs.Logr.Info("Processing existing sources from cache", "count", len(sources))
+	for _, source := range sources {
+		c.processSource(source, false)
+	}
+	return nil
+}
+
+// recordOptions holds the settings parsed from a source's routeflare annotations
+type recordOptions struct {
+	contentMode       string
+	recordType        string
+	ttl               int
+	proxied           bool
+	allAddresses      bool
+	allowApexWildcard bool
+}
+
+// parseRecordOptions parses the routeflare annotations on a source, returning false if it has no content-mode
+// RouteflareRecords are always published, with the settings in their spec
+func (c *Controller) parseRecordOptions(obj *unstructured.Unstructured) (recordOptions, bool) {
+	if obj.GetKind() == kubernetes.RecordKind {
+		return parseRecordSpec(obj), true
+	}
+
+	// Extract routeflare annotations
+	routeflareAnns := c.extractRouteflareAnnotations(obj.GetAnnotations())
+	if len(routeflareAnns) == 0 {
+		return recordOptions{}, false // No routeflare annotations, skip
+	}
+
+	// Check for required content-mode annotation
+	opts := recordOptions{contentMode: routeflareAnns["content-mode"]}
+	if opts.contentMode == "" {
+		return recordOptions{}, false // No content-mode, skip
+	}
+
+	// Parse other annotations
+	opts.recordType = routeflareAnns["type"]
+	if opts.recordType == "" {
+		opts.recordType = "A" // Default to A
+	}
+
+	var err error
+	opts.ttl, err = provider.ParseTTL(routeflareAnns["ttl"])
+	if err != nil {
+		slogs.Logr.Error("parsing TTL",
+			"source", objectKey(obj),
+			"error", err)
+		opts.ttl = 1 // Default to auto
+	}
+
+	opts.proxied, err = provider.ParseProxied(routeflareAnns["proxied"])
+	if err != nil {
+		slogs.Logr.Error("parsing proxied",
+			"source", objectKey(obj),
+			"error", err)
+		opts.proxied = false
+	}
+
+	opts.allAddresses, err = strconv.ParseBool(routeflareAnns["all-addresses"])
+	if err != nil && routeflareAnns["all-addresses"] != "" {
+		slogs.Logr.Error("parsing all-addresses",
+			"source", objectKey(obj),
+			"error", err)
+	}
+
+	opts.allowApexWildcard, err = strconv.ParseBool(routeflareAnns["allow-apex-wildcard"])
+	if err != nil && routeflareAnns["allow-apex-wildcard"] != "" {
+		slogs.Logr.Error("parsing allow-apex-wildcard",
+			"source", objectKey(obj),
+			"error", err)
+	}
+
+	return opts, true
+}
+
+// getRecordNames returns the record names a source publishes records for
+// Gateways publish a record for each listener hostname, Services publish a record for each name in their hostname
+// annotation, Ingresses publish a record for each rule host, RouteflareRecords publish a record for their hostname,
+// and routes publish a record for each hostname
+func (c *Controller) getRecordNames(obj *unstructured.Unstructured) ([]string, error) {
+	switch obj.GetKind() {
+	case kubernetes.RecordKind:
+		return getRecordNamesFromRecord(obj)
+	case "Gateway":
+		return gateway.GetListenerHostnames(obj)
+	case "Service":
+		return c.getRecordNamesFromService(obj)
+	case "Ingress":
+		return getRecordNamesFromIngress(obj)
+	default:
+		return getRecordNamesFromRoute(obj)
+	}
+}
+
+// processSource publishes the DNS records for a single source, which is a route, a Gateway, a Service, an Ingress, or a RouteflareRecord
+func (c *Controller) processSource(obj *unstructured.Unstructured, isReconciliationUpdate bool) {
+	if obj.GetDeletionTimestamp() != nil {
+		c.finalizeSource(obj)
+		return
+	}
+
+	opts, ok := c.parseRecordOptions(obj)
+	if !ok || !c.usesFinalizer() {
+		c.removeFinalizer(obj) // No longer managed, or the strategy changed
+	}
+	if !ok {
+		return
+	}
+	if c.usesFinalizer() {
+		c.addFinalizer(obj)
+	}
+
+	err := c.publishSource(obj, opts, isReconciliationUpdate)
+	switch {
+	case err == nil:
+	case isOwnershipConflict(err):
+		slogs.Logr.Warn("Skipping record due to ownership conflict",
+			"source", objectKey(obj),
+			"error", err)
+	default:
+		slogs.Logr.Error("publishing records",
+			"source", objectKey(obj),
+			"error", err)
+	}
+	if err != nil {
+		c.recordErrorEvent(obj, err)
+	}
+
+	switch {
+	case obj.GetKind() == kubernetes.RecordKind:
+		c.updateRecordStatus(obj, err)
+	case c.cfg.StatusAnnotation:
+		c.updateSourceStatus(obj, err)
+	}
+}
+
+// publishSource publishes a source's records for each of its record names, and deletes the records of names it no longer has
+func (c *Controller) publishSource(obj *unstructured.Unstructured, opts recordOptions, isReconciliationUpdate bool) error {
+	recordNames, err := c.getRecordNames(obj)
+	if err != nil {
+		// The source has no record names left, such as a route whose hostnames were all removed, so every name it had is stale
+		return errors.Join(fmt.Errorf("error getting record names: %w", err), c.removeStaleRecordNames(obj, nil))
+	}
+
+	// Process based on content mode
+	var errs []error
+	switch opts.contentMode {
+	case "gateway-address":
+		gatewayObj, err := c.getSourceGateway(obj)
+		if err != nil {
+			return errors.Join(fmt.Errorf("error getting Gateway: %w", err), c.removeStaleRecordNames(obj, recordNames))
+		}
+		for _, recordName := range recordNames {
+			errs = append(errs, c.processGatewayAddressMode(obj, gatewayObj, recordName, opts))
+		}
+	case "load-balancer-address":
+		for _, recordName := range recordNames {
+			errs = append(errs, c.processLoadBalancerAddressMode(obj, recordName, opts))
+		}
+	case "static":
+		for _, recordName := range recordNames {
+			errs = append(errs, c.processStaticMode(obj, recordName, opts))
+		}
+	case "ddns":
+		for _, recordName := range recordNames {
+			errs = append(errs, c.processDDNSMode(obj, recordName, opts, isReconciliationUpdate))
+		}
+	default:
+		return fmt.Errorf("unknown content-mode %q", opts.contentMode)
+	}
+
+	errs = append(errs, c.removeStaleRecordNames(obj, recordNames))
+	return errors.Join(errs...)
+}
+
+// getSourceGateway returns the Gateway whose addresses a source's records point to
+// A Gateway uses its own addresses, and a route uses the addresses of the first Gateway in its parentRefs it can attach to,
+// or the Gateway chosen by its gateway annotation, and a RouteflareRecord uses the addresses of the Gateway it references
+func (c *Controller) getSourceGateway(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	switch obj.GetKind() {
+	case "Gateway":
+		return obj, nil
+	case kubernetes.RecordKind:
+		return c.getRecordGateway(obj)
+	}
+
+	parentRefs, err := c.getRouteParentRefs(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, ref := range parentRefs {
+		gatewayObj, err := c.k8sClient.GetGateway(ref.Namespace, ref.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting Gateway %s: %w", ref.Key(), err))
+			continue
+		}
+
+		// A parentRef's sectionName and port narrow it down to the Gateway's matching listeners
+		if !gateway.HasListener(gatewayObj, ref) {
+			errs = append(errs, fmt.Errorf("gateway %s has no listener matching sectionName %q and port %d", ref.Key(), ref.SectionName, ref.Port))
+			continue
+		}
+		return gatewayObj, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// getRouteParentRefs returns the references to the Gateways a route's records may point to, which are the Gateways in its parentRefs,
+// narrowed down to the one chosen by its gateway annotation if it has one
+func (c *Controller) getRouteParentRefs(obj *unstructured.Unstructured) ([]gateway.ParentRef, error) {
+	parentRefs := gateway.GetParentRefs(obj)
+	if len(parentRefs) == 0 {
+		return nil, fmt.Errorf("route does not have a Gateway in its parentRefs")
+	}
+
+	// The gateway annotation chooses between Gateways when a route attaches to several of them
+	if selected := c.extractRouteflareAnnotations(obj.GetAnnotations())["gateway"]; selected != "" {
+		if !strings.Contains(selected, "/") {
+			selected = obj.GetNamespace() + "/" + selected // Default to the route's namespace
+		}
+
+		var selectedRefs []gateway.ParentRef
+		for _, ref := range parentRefs {
+			if ref.Key() == selected {
+				selectedRefs = append(selectedRefs, ref)
+			}
+		}
+		if len(selectedRefs) == 0 {
+			return nil, fmt.Errorf("gateway annotation %s does not match a Gateway in the route's parentRefs", selected)
+		}
+		parentRefs = selectedRefs
+	}
+	return parentRefs, nil
+}
+
+// processGatewayAddressMode publishes a source's record with gateway-address content mode
+func (c *Controller) processGatewayAddressMode(obj, gatewayObj *unstructured.Unstructured, recordName string, opts recordOptions) error {
+	// Extract IP addresses from Gateway, falling back to the Gateway's hostname if it has no IP addresses
+	var cnameTarget string
+	ips, err := gateway.GetGatewayAddresses(gatewayObj, opts.recordType, opts.allAddresses)
+	if err != nil {
+		hostname, found := gateway.GetGatewayHostname(gatewayObj)
+		if !found {
+			return fmt.Errorf("error getting Gateway %s/%s addresses: %w", gatewayObj.GetNamespace(), gatewayObj.GetName(), err)
+		}
+		cnameTarget = hostname
+	}
+
+	return c.publishAddresses(obj, recordName, opts, ips, cnameTarget, &trackedRoute{
+		gatewayNamespace: gatewayObj.GetNamespace(),
+		gatewayName:      gatewayObj.GetName(),
+	})
+}
+
+// processLoadBalancerAddressMode publishes a source's record with load-balancer-address content mode
+func (c *Controller) processLoadBalancerAddressMode(obj *unstructured.Unstructured, recordName string, opts recordOptions) error {
+	// Extract IP addresses from the load balancer status, falling back to its hostname if it has no IP addresses
+	var cnameTarget string
+	ips, err := gateway.GetLoadBalancerAddresses(obj, opts.recordType, opts.allAddresses)
+	if err != nil {
+		hostname, found := gateway.GetLoadBalancerHostname(obj)
+		if !found {
+			return fmt.Errorf("error getting load balancer addresses: %w", err)
+		}
+		cnameTarget = hostname
+	}
+
+	return c.publishAddresses(obj, recordName, opts, ips, cnameTarget, &trackedRoute{})
+}
+
+// publishAddresses publishes a source's record pointing at a set of IP addresses, or at a hostname with a CNAME record,
+// and tracks it using the given tracked record, which holds any content mode specific fields
+// For reconciliation, we always update to fix any drift (e.g., manual DNS changes in Cloudflare)
+// even if the addresses haven't changed. This ensures DNS records always match the addresses.
+// The record is tracked even if publishing it fails, so reconciliation tries again, but keeps the addresses it was last published with.
+func (c *Controller) publishAddresses(obj *unstructured.Unstructured, recordName string, opts recordOptions, ips []string, cnameTarget string, tracked *trackedRoute) error {
+	// Get the zone the record belongs to
+	zone, err := c.findRecordZone(recordName, opts)
+	if err != nil {
+		return err
+	}
+
+	// A CNAME can't share a name with any other record, so when the addresses switch between IPs and a hostname,
+	// remove the records of the old kind first. Untracked sources are checked too, in case the switch
+	// happened while routeflare wasn't running.
+	key := trackingKey(obj, recordName)
+	c.routesMutex.RLock()
+	previous, exists := c.trackedRoutes[key]
+	c.routesMutex.RUnlock()
+	var staleErr error
+	if !exists || (previous.cnameTarget == "") != (cnameTarget == "") {
+		staleTypes := []provider.RecordType{provider.RecordTypeCNAME}
+		if cnameTarget != "" {
+			staleTypes = []provider.RecordType{provider.RecordTypeA, provider.RecordTypeAAAA}
+		}
+		staleErr = c.deleteRecords(obj, zone.ID, recordName, staleTypes)
+	}
+
+	// Create/update DNS records (always update to ensure reconciliation fixes drift)
+	if cnameTarget != "" {
+		err = c.createOrUpdateCNAME(obj, opts, zone.ID, recordName, cnameTarget)
+	} else {
+		err = c.createOrUpdateRecords(obj, opts, zone.ID, recordName, ips, true)
+	}
+	// The records of the old kind are deleted again next time if they couldn't be, since the switch isn't recorded
+	err = errors.Join(staleErr, err)
+
+	// Store source info for periodic reconciliation
+	tracked.contentMode = opts.contentMode
+	tracked.kind = obj.GetKind()
+	tracked.namespace = obj.GetNamespace()
+	tracked.name = obj.GetName()
+	tracked.zoneName = zone.Name
+	tracked.recordName = recordName
+	tracked.recordType = opts.recordType
+	tracked.ttl = opts.ttl
+	tracked.proxied = opts.proxied
+	if err == nil {
+		// Only report the addresses in the source's status once they were published
+		tracked.lastIPs = ips
+		tracked.cnameTarget = cnameTarget
+	} else if exists {
+		tracked.lastIPs = previous.lastIPs
+		tracked.cnameTarget = previous.cnameTarget
+	}
+	c.routesMutex.Lock()
+	c.trackedRoutes[key] = tracked
+	c.routesMutex.Unlock()
+	return err
+}
+
+// findRecordZone finds the zone a source's record belongs to, returning an error if there isn't one it can be published in
+func (c *Controller) findRecordZone(recordName string, opts recordOptions) (*provider.Zone, error) {
+	zone, err := c.dnsProvider.FindZone(c.ctx, recordName)
+	if err != nil {
+		return nil, fmt.Errorf("error finding zone for %s: %w", recordName, err)
+	}
+
+	// A wildcard directly under the zone apex answers for every name in the zone that has no record of its own,
+	// including typos and names managed by other tools, so it must be opted into
+	if isApexWildcard(recordName, zone.Name) && !opts.allowApexWildcard {
+		return nil, fmt.Errorf("not publishing %s, a wildcard at the zone apex, without allow-apex-wildcard set", recordName)
+	}
+	return zone, nil
+}
+
+// processDDNSMode publishes a source's record with ddns content mode
+// The record is tracked even if publishing it fails, so reconciliation tries again.
+func (c *Controller) processDDNSMode(obj *unstructured.Unstructured, recordName string, opts recordOptions, isReconciliationUpdate bool) error {
+	// Get current public IPs
+	ips, err := c.ddnsDetector.GetPublicIPsByType(c.ctx, opts.recordType)
+	if err != nil {
+		return fmt.Errorf("error getting public IPs: %w", err)
+	}
+
+	// Check if IPs have changed (only for reconciliation updates, not initial processing)
+	key := trackingKey(obj, recordName)
+	if isReconciliationUpdate {
+		c.routesMutex.RLock()
+		trackedRoute, exists := c.trackedRoutes[key]
+		c.routesMutex.RUnlock()
+
+		if exists && ipsEqual(trackedRoute.lastIPs, ips) {
+			return nil // IPs haven't changed, skip update
+		}
+	}
+
+	// Get the zone the record belongs to
+	zone, err := c.findRecordZone(recordName, opts)
+	if err != nil {
+		return err
+	}
+
+	// Create/update DNS records, keeping the records of an address family that couldn't be detected
+	err = c.createOrUpdateRecords(obj, opts, zone.ID, recordName, ips, false)
+
+	// Store source info for periodic reconciliation
+	tracked := &trackedRoute{
+		contentMode: opts.contentMode,
+		kind:        obj.GetKind(),
+		namespace:   obj.GetNamespace(),
+		name:        obj.GetName(),
+		zoneName:    zone.Name,
+		recordName:  recordName,
+		recordType:  opts.recordType,
+		ttl:         opts.ttl,
+		proxied:     opts.proxied,
+	}
+	if err == nil {
+		tracked.lastIPs = ips // Only skip the next reconciliation if the IPs were published
+	}
+	c.routesMutex.Lock()
+	c.trackedRoutes[key] = tracked
+	c.routesMutex.Unlock()
+	return err
+}
+
+// createOrUpdateRecords publishes a source's records with one record per IP address, as a record set for each record type
+// With deleteMissing, the set of a managed record type with no addresses, such as AAAA records after a Gateway loses its IPv6 address, is deleted
+func (c *Controller) createOrUpdateRecords(obj *unstructured.Unstructured, opts recordOptions, zoneID string, recordName string, ips []string, deleteMissing bool) error {
+	recordType := opts.recordType
+	if len(ips) == 0 {
+		return fmt.Errorf("no IP addresses found for record type %s", recordType)
+	}
+
+	// Group addresses into record sets by the record type they belong in
+	recordSets := make(map[provider.RecordType][]provider.Record)
+	for _, ip := range ips {
+		var recordTypeForIP provider.RecordType
+		if isIPv6(ip) {
+			recordTypeForIP = provider.RecordTypeAAAA
+		}
+		if isIPv4(ip) {
+			recordTypeForIP = provider.RecordTypeA
+		}
+		if recordTypeForIP == "" {
+			slogs.Logr.Warn("Skipping invalid IP address", "ip", ip)
+			continue
+		}
+		if recordType != "A/AAAA" && string(recordTypeForIP) != recordType {
+			continue
+		}
+
+		recordSets[recordTypeForIP] = append(recordSets[recordTypeForIP], provider.Record{
+			Type:    recordTypeForIP,
+			Name:    recordName,
+			Content: ip,
+			TTL:     opts.ttl,
+			Proxied: opts.proxied,
+			OwnerID: c.cfg.RecordOwnerID,
+			Source:  recordSource(obj, opts.contentMode),
+		})
+	}
+	if len(recordSets) == 0 {
+		return fmt.Errorf("no valid IP addresses found for record type %s", recordType)
+	}
+
+	managedTypes := []provider.RecordType{provider.RecordType(recordType)}
+	if recordType == "A/AAAA" {
+		managedTypes = []provider.RecordType{provider.RecordTypeA, provider.RecordTypeAAAA}
+	}
+
+	var errs []error
+	for _, rt := range managedTypes {
+		records, ok := recordSets[rt]
+		if !ok {
+			if !deleteMissing {
+				continue
+			}
+			if err := c.deleteRecords(obj, zoneID, recordName, []provider.RecordType{rt}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		changes, err := c.dnsProvider.UpsertRecordSet(c.ctx, zoneID, records)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error upserting %s records for %s: %w", rt, recordName, err))
+			continue
+		}
+		c.recordChangeEvents(obj, changes)
+	}
+
+	return errors.Join(errs...)
+}
+
+// createOrUpdateCNAME publishes a source's CNAME record pointing at a target hostname
+func (c *Controller) createOrUpdateCNAME(obj *unstructured.Unstructured, opts recordOptions, zoneID string, recordName string, target string) error {
+	record := provider.Record{
+		Type:    provider.RecordTypeCNAME,
+		Name:    recordName,
+		Content: target,
+		TTL:     opts.ttl,
+		Proxied: opts.proxied,
+		OwnerID: c.cfg.RecordOwnerID,
+		Source:  recordSource(obj, opts.contentMode),
+	}
+
+	changes, err := c.dnsProvider.UpsertRecordSet(c.ctx, zoneID, []provider.Record{record})
+	if err != nil {
+		return fmt.Errorf("error upserting %s record for %s: %w", record.Type, recordName, err)
+	}
+	c.recordChangeEvents(obj, changes)
+
+	return nil
+}
+
+// deleteRecords deletes the records of each of the given types with a record name, returning every failure but ownership conflicts
+// Events are recorded on the source the records belong to, which may be nil, leaving the Events of returned failures to the caller
+func (c *Controller) deleteRecords(obj *unstructured.Unstructured, zoneID string, recordName string, recordTypes []provider.RecordType) error {
+	var errs []error
+	for _, rt := range recordTypes {
+		record := provider.Record{
+			Type:    rt,
+			Name:    recordName,
+			OwnerID: c.cfg.RecordOwnerID,
+		}
+		deleted, err := c.dnsProvider.DeleteRecord(c.ctx, zoneID, record)
+		if err != nil {
+			slogs.Logr.Error("deleting record", "type", rt, "name", recordName, "error", err)
+			err = fmt.Errorf("error deleting %s records for %s: %w", rt, recordName, err)
+			if isOwnershipConflict(err) {
+				c.recordErrorEvent(obj, err) // Not returned, so the caller can't record it
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		c.recordChangeEvents(obj, provider.RecordSetChanges{Delete: deleted})
+	}
+	return errors.Join(errs...)
+}
+
+// isOwnershipConflict checks if an error is an ownership conflict
+func isOwnershipConflict(err error) bool {
+	return errors.Is(err, provider.ErrOwnershipConflict)
+}
+
+// processSourceDeletion deletes the DNS records of a deleted source, returning every failure to delete them
+func (c *Controller) processSourceDeletion(obj *unstructured.Unstructured) error {
+	if c.cfg.ShouldDelete() {
+		if opts, ok := c.parseRecordOptions(obj); ok {
+			recordNames, err := c.getRecordNames(obj)
+			if err != nil {
+				slogs.Logr.Error("getting record names from deleted source",
+					"source", objectKey(obj),
+					"error", err)
+			}
+			// Record names the source isn't tracked with, such as when it was deleted while routeflare wasn't running, are deleted too
+			for _, recordName := range recordNames {
+				c.trackRecordName(&trackedRoute{
+					contentMode: opts.contentMode,
+					kind:        obj.GetKind(),
+					namespace:   obj.GetNamespace(),
+					name:        obj.GetName(),
+					recordName:  recordName,
+					recordType:  opts.recordType,
+				})
+			}
+		}
+	}
+
+	// Record names whose records couldn't be deleted stay tracked, so deleting them is retried by the reconciliation job
+	return c.deleteTrackedRecordNames(objectKey(obj), nil, nil)
+}
+
+// removeStaleRecordNames stops tracking the record names a source no longer publishes, such as a removed hostname,
+// and deletes their records
+func (c *Controller) removeStaleRecordNames(obj *unstructured.Unstructured, recordNames []string) error {
+	current := make(map[string]bool, len(recordNames))
+	for _, recordName := range recordNames {
+		current[recordName] = true
+	}
+	return c.deleteTrackedRecordNames(objectKey(obj), obj, current)
+}
+
+// deleteTrackedRecordNames stops tracking a source's record names, except for the ones to keep, and deletes their records
+// Events are recorded on the source, unless it is nil because it has been deleted
+// A record name whose records couldn't be deleted stays tracked, so deleting them is retried
+func (c *Controller) deleteTrackedRecordNames(sourceKey string, obj *unstructured.Unstructured, keep map[string]bool) error {
+	var errs []error
+	for _, stale := range c.untrackSource(sourceKey, keep) {
+		if !c.cfg.ShouldDelete() {
+			continue // Upsert-only strategy, don't delete
+		}
+		slogs.Logr.Info("Deleting records for record name", "source", sourceKey, "record", stale.recordName)
+		if err := c.deleteSourceRecords(obj, stale.recordName, stale.recordType, stale.contentMode); err != nil {
+			c.trackRecordName(stale)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// trackRecordName tracks a source's record name, unless it's already tracked, such as by publishing it
+func (c *Controller) trackRecordName(tracked *trackedRoute) {
+	c.routesMutex.Lock()
+	defer c.routesMutex.Unlock()
+
+	key := tracked.sourceKey() + "/" + tracked.recordName
+	if _, exists := c.trackedRoutes[key]; !exists {
+		c.trackedRoutes[key] = tracked
+	}
+}
+
+// untrackSource stops tracking a source's record names, except for the ones to keep, and returns what was untracked
+func (c *Controller) untrackSource(sourceKey string, keep map[string]bool) []*trackedRoute {
+	c.routesMutex.Lock()
+	defer c.routesMutex.Unlock()
+
+	var untracked []*trackedRoute
+	for key, tracked := range c.trackedRoutes {
+		if tracked.sourceKey() != sourceKey || keep[tracked.recordName] {
+			continue
+		}
+		delete(c.trackedRoutes, key)
+		untracked = append(untracked, tracked)
+	}
+	return untracked
+}
+
+// deleteSourceRecords deletes the records a source published for a record name
+// Events are recorded on the source, unless it is nil because it has been deleted
+// A record name with no zone never had records published, so there is nothing to delete
+func (c *Controller) deleteSourceRecords(obj *unstructured.Unstructured, recordName, recordType, contentMode string) error {
+	// Get the zone the record belongs to
+	zone, err := c.dnsProvider.FindZone(c.ctx, recordName)
+	if err != nil {
+		slogs.Logr.Error("finding zone for record name", "record", recordName, "error", err)
+		if errors.Is(err, provider.ErrZoneNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error finding zone for %s: %w", recordName, err)
+	}
+
+	// Delete DNS records
+	return c.deleteRecords(obj, zone.ID, recordName, sourceRecordTypes(recordType, contentMode))
+}
+
+// sourceRecordTypes returns the types of record a source may publish for a record name, given its record type and content mode
+func sourceRecordTypes(recordType, contentMode string) []provider.RecordType {
+	recordTypes := []provider.RecordType{provider.RecordType(recordType)}
+	if recordType == "A/AAAA" {
+		recordTypes = []provider.RecordType{provider.RecordTypeA, provider.RecordTypeAAAA}
+	}
+	if contentMode == "gateway-address" || contentMode == "load-balancer-address" {
+		// The Gateway or load balancer may have a hostname address, published as a CNAME
+		recordTypes = append(recordTypes, provider.RecordTypeCNAME)
+	}
+	return recordTypes
+}
+
+// runReconciliationJob runs a background job to reconcile all tracked sources
+// This ensures DNS records stay in sync even if manually changed in Cloudflare
+func (c *Controller) runReconciliationJob() {
+	ticker := time.NewTicker(c.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			// Use informer caches to get all sources, and build a map of them for quick lookup
+			cacheSources := make(map[string]*unstructured.Unstructured)
+			for _, obj := range c.listSources() {
+				cacheSources[objectKey(obj)] = obj
+			}
+
+			c.routesMutex.RLock()
+			trackedRoutes := make([]*trackedRoute, 0, len(c.trackedRoutes))
+			for _, route := range c.trackedRoutes {
+				trackedRoutes = append(trackedRoutes, route)
+			}
+			c.routesMutex.RUnlock()
+
+			// A source with several record names is tracked once per record name, but only needs processing once
+			processed := make(map[string]bool)
+			for _, trackedRoute := range trackedRoutes {
+				key := trackedRoute.sourceKey()
+				if processed[key] {
+					continue
+				}
+				processed[key] = true
+
+				obj, exists := cacheSources[key]
+				if !exists {
+					// Source no longer exists in cache, so its records are deleted if that failed when it was deleted
+					slogs.Logr.Info("Source no longer exists, removing from tracking",
+						"source", key)
+					if err := c.deleteTrackedRecordNames(key, nil, nil); err != nil {
+						slogs.Logr.Error("deleting records of deleted source", "source", key, "error", err)
+					}
+					continue
+				}
+
+				switch trackedRoute.contentMode {
+				case "ddns":
+					// For DDNS, check if public IPs have changed
+					c.processSource(obj, true)
+				case "gateway-address", "load-balancer-address", "static":
+					// For gateway-address, load-balancer-address, and static, reconcile out state drift
+					c.processSource(obj, true)
+				default:
+					slogs.Logr.Warn("Unknown content mode during reconciliation",
+						"source", key,
+						"contentMode", trackedRoute.contentMode)
+				}
+			}
+
+			// Retry deleting the records of sources being deleted, which routeflare's finalizer is still holding
+			for key, obj := range cacheSources {
+				if !processed[key] && obj.GetDeletionTimestamp() != nil {
+					c.finalizeSource(obj)
+				}
+			}
+		}
+	}
+}
+
+// listSources lists the sources in scope in the informer caches
+func (c *Controller) listSources() []*unstructured.Unstructured {
+	var sources []*unstructured.Unstructured
+	for _, obj := range c.listCachedSources() {
+		if c.k8sClient.WatchesNamespace(obj.GetNamespace()) {
+			sources = append(sources, obj)
+		}
+	}
+	return sources
+}
+
+// listCachedSources lists every source in the informer caches, including the ones in namespaces that aren't in scope
+func (c *Controller) listCachedSources() []*unstructured.Unstructured {
+	var sources []*unstructured.Unstructured
+	for _, informers := range c.k8sClient.GetSourceInformers() {
+		for _, informer := range informers {
+			for _, item := range informer.GetStore().List() {
+				if obj, ok := item.(*unstructured.Unstructured); ok {
+					sources = append(sources, obj)
+				}
+			}
+		}
+	}
+	return sources
+}
+
+// Helper funcs
+
+// objectKey returns the key a source is identified by, which includes its kind since objects of different kinds may share a name
+func objectKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// trackingKey returns the key a source's record is tracked by, since a source may publish several record names
+func trackingKey(obj *unstructured.Unstructured, recordName string) string {
+	return objectKey(obj) + "/" + recordName
+}
+
+// getRecordNamesFromService gets the comma separated names in a LoadBalancer Service's hostname annotation
+func (c *Controller) getRecordNamesFromService(service *unstructured.Unstructured) ([]string, error) {
+	serviceType, _, _ := unstructured.NestedString(service.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return nil, fmt.Errorf("service is of type %s, not LoadBalancer", serviceType)
+	}
+
+	var recordNames []string
+	for _, recordName := range strings.Split(c.extractRouteflareAnnotations(service.GetAnnotations())["hostname"], ",") {
+		if recordName = strings.TrimSpace(recordName); recordName != "" {
+			recordNames = append(recordNames, recordName)
+		}
+	}
+	if len(recordNames) == 0 {
+		return nil, fmt.Errorf("service has no hostname annotation")
+	}
+	return recordNames, nil
+}
+
+// getRecordNamesFromIngress gets the distinct hosts of an Ingress's rules
+func getRecordNamesFromIngress(ingress *unstructured.Unstructured) ([]string, error) {
+	rules, found, err := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	if !found || err != nil {
+		return nil, fmt.Errorf("ingress has no rules in spec")
+	}
+
+	var recordNames []string
+	seen := make(map[string]bool)
+	for _, ruleInterface := range rules {
+		rule, ok := ruleInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		host, found, err := unstructured.NestedString(rule, "host")
+		if !found || err != nil || host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		recordNames = append(recordNames, host)
+	}
+
+	if len(recordNames) == 0 {
+		return nil, fmt.Errorf("ingress has no rules with a host")
+	}
+	return recordNames, nil
+}
+
+// getRecordNamesFromRoute gets the distinct hostnames from a route
+func getRecordNamesFromRoute(route *unstructured.Unstructured) ([]string, error) {
+	hostnames, found, err := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if !found || err != nil || len(hostnames) == 0 {
+		return nil, fmt.Errorf("%s has no hostnames in spec", route.GetKind())
+	}
+
+	var recordNames []string
+	seen := make(map[string]bool)
+	for _, hostname := range hostnames {
+		if hostname == "" || seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+		recordNames = append(recordNames, hostname)
+	}
+	return recordNames, nil
+}
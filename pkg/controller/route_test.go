@@ -0,0 +1,479 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	cf "github.com/cloudflare/cloudflare-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/starttoaster/routeflare/pkg/cloudflare"
+	"github.com/starttoaster/routeflare/pkg/cloudflare/cloudflaretest"
+	"github.com/starttoaster/routeflare/pkg/config"
+	"github.com/starttoaster/routeflare/pkg/gateway"
+	"github.com/starttoaster/routeflare/pkg/kubernetes"
+	"github.com/starttoaster/routeflare/pkg/provider"
+	"github.com/starttoaster/routeflare/pkg/provider/inmemory"
+)
+
+func TestMain(m *testing.M) {
+	slogs.Init("error")
+	os.Exit(m.Run())
+}
+
+// newTestController returns a controller publishing to a fake Cloudflare API server with a zone named example.com,
+// along with the server, the zone's ID, and the recorder of the controller's Events
+func newTestController(t *testing.T) (*Controller, *cloudflaretest.Server, string, *record.FakeRecorder) {
+	t.Helper()
+
+	server := cloudflaretest.NewServer()
+	t.Cleanup(server.Close)
+	zoneID := server.AddZone("example.com")
+
+	dnsProvider, err := cloudflare.NewClient("test-token", cloudflare.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("creating Cloudflare client: %v", err)
+	}
+
+	c, recorder := newTestControllerWithProvider(t, dnsProvider)
+	return c, server, zoneID, recorder
+}
+
+// newInMemoryTestController returns a controller publishing to an in-memory DNS provider with a zone named example.com,
+// along with the provider, the zone's ID, and the recorder of the controller's Events
+func newInMemoryTestController(t *testing.T) (*Controller, *inmemory.Provider, string, *record.FakeRecorder) {
+	t.Helper()
+
+	dnsProvider := inmemory.NewProvider()
+	zoneID := dnsProvider.AddZone("example.com")
+	c, recorder := newTestControllerWithProvider(t, dnsProvider)
+	return c, dnsProvider, zoneID, recorder
+}
+
+// newTestControllerWithProvider returns a controller publishing to a DNS provider, along with the recorder of its Events
+func newTestControllerWithProvider(t *testing.T, dnsProvider provider.Provider) (*Controller, *record.FakeRecorder) {
+	t.Helper()
+
+	recorder := record.NewFakeRecorder(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Controller{
+		cfg: &config.Config{
+			RecordOwnerID:    "routeflare",
+			Strategy:         config.StrategyFull,
+			AnnotationPrefix: config.LegacyAnnotationPrefix,
+		},
+		dnsProvider:   dnsProvider,
+		eventRecorder: recorder,
+		ctx:           ctx,
+		cancel:        cancel,
+		trackedRoutes: make(map[string]*trackedRoute),
+	}, recorder
+}
+
+func testRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetAPIVersion("gateway.networking.k8s.io/v1")
+	route.SetKind("HTTPRoute")
+	route.SetNamespace("default")
+	route.SetName("app")
+	return route
+}
+
+// zoneContents returns the type and content of each record in a zone, sorted
+func zoneContents(server *cloudflaretest.Server, zoneID string) []string {
+	var contents []string
+	for _, record := range server.Records(zoneID) {
+		contents = append(contents, record.Type+" "+record.Content)
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+// drainEvents returns the Events recorded so far
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestCreateOrUpdateRecords(t *testing.T) {
+	c, server, zoneID, recorder := newTestController(t)
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A/AAAA", ttl: 1}
+
+	err := c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}, true)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+
+	want := []string{"A 192.0.2.1", "A 192.0.2.2", "AAAA 2001:db8::1"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+	for _, record := range server.Records(zoneID) {
+		if !strings.HasPrefix(record.Comment, "record-owner-id=routeflare kind=HTTPRoute namespace=default name=app") {
+			t.Errorf("got comment %q on %s record", record.Comment, record.Type)
+		}
+	}
+	if events := drainEvents(recorder); len(events) != 3 {
+		t.Errorf("got Events %v, want one RecordCreated per record", events)
+	}
+
+	// Publishing the same addresses again changes nothing
+	err = c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}, true)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+	if events := drainEvents(recorder); len(events) != 0 {
+		t.Errorf("got Events %v for records that were already up to date", events)
+	}
+}
+
+func TestCreateOrUpdateRecordsOwnershipConflict(t *testing.T) {
+	c, server, zoneID, recorder := newTestController(t)
+	server.AddRecord(zoneID, cf.DNSRecord{
+		Type:    "A",
+		Name:    "app.example.com",
+		Content: "198.51.100.1",
+		TTL:     1,
+		Comment: "record-owner-id=someone-else",
+	})
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A", ttl: 1}
+
+	err := c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1"}, true)
+	if !errors.Is(err, provider.ErrOwnershipConflict) {
+		t.Fatalf("createOrUpdateRecords error = %v, want an ownership conflict", err)
+	}
+
+	want := []string{"A 198.51.100.1"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+	if events := drainEvents(recorder); len(events) != 0 {
+		t.Errorf("got Events %v, want none", events)
+	}
+}
+
+func TestCreateOrUpdateRecordsRemovesEmptyRecordType(t *testing.T) {
+	c, server, zoneID, recorder := newTestController(t)
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A/AAAA", ttl: 1}
+
+	err := c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1", "2001:db8::1"}, true)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+	drainEvents(recorder)
+
+	// The source loses its IPv6 address
+	err = c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1"}, true)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+
+	want := []string{"A 192.0.2.1"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+	if events := drainEvents(recorder); len(events) != 1 || !strings.Contains(events[0], reasonRecordDeleted) {
+		t.Errorf("got Events %v, want one RecordDeleted", events)
+	}
+}
+
+func TestCreateOrUpdateRecordsKeepsUndetectedRecordType(t *testing.T) {
+	c, server, zoneID, recorder := newTestController(t)
+	opts := recordOptions{contentMode: "ddns", recordType: "A/AAAA", ttl: 1}
+
+	err := c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.1", "2001:db8::1"}, false)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+	drainEvents(recorder)
+
+	// The public IPv6 address couldn't be detected, which doesn't mean there isn't one
+	err = c.createOrUpdateRecords(testRoute(), opts, zoneID, "app.example.com", []string{"192.0.2.2"}, false)
+	if err != nil {
+		t.Fatalf("createOrUpdateRecords: %v", err)
+	}
+
+	want := []string{"A 192.0.2.2", "AAAA 2001:db8::1"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+	for _, event := range drainEvents(recorder) {
+		if strings.Contains(event, reasonRecordDeleted) {
+			t.Errorf("got Event %q, want no deletions", event)
+		}
+	}
+}
+
+func TestGetRouteParentRefs(t *testing.T) {
+	c, _, _, _ := newInMemoryTestController(t)
+	parentRefs := []interface{}{
+		map[string]interface{}{"kind": "Service", "group": "", "name": "mesh"},
+		map[string]interface{}{"name": "public"},
+		map[string]interface{}{"namespace": "infra", "name": "internal", "sectionName": "https"},
+	}
+
+	tests := []struct {
+		name       string
+		annotation string
+		want       []gateway.ParentRef
+		wantErr    bool
+	}{
+		{
+			name: "every Gateway in order",
+			want: []gateway.ParentRef{{Namespace: "default", Name: "public"}, {Namespace: "infra", Name: "internal", SectionName: "https"}},
+		},
+		{
+			name:       "annotation in the route's namespace",
+			annotation: "public",
+			want:       []gateway.ParentRef{{Namespace: "default", Name: "public"}},
+		},
+		{
+			name:       "annotation with a namespace",
+			annotation: "infra/internal",
+			want:       []gateway.ParentRef{{Namespace: "infra", Name: "internal", SectionName: "https"}},
+		},
+		{
+			name:       "annotation without a matching parentRef",
+			annotation: "internal",
+			wantErr:    true,
+		},
+		{
+			name:       "annotation naming a parent that isn't a Gateway",
+			annotation: "mesh",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := testRoute()
+			_ = unstructured.SetNestedSlice(route.Object, parentRefs, "spec", "parentRefs")
+			if tt.annotation != "" {
+				route.SetAnnotations(map[string]string{"routeflare/gateway": tt.annotation})
+			}
+
+			got, err := c.getRouteParentRefs(route)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRouteParentRefs() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRouteParentRefs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.getRouteParentRefs(testRoute()); err == nil {
+		t.Error("getRouteParentRefs succeeded for a route without parentRefs")
+	}
+}
+
+func TestPublishAddressesCNAMEAtZoneApex(t *testing.T) {
+	c, server, zoneID, _ := newTestController(t)
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A", ttl: 1}
+
+	err := c.publishAddresses(testRoute(), "example.com", opts, nil, "lb.elb.example.net", &trackedRoute{})
+	if err != nil {
+		t.Fatalf("publishAddresses: %v", err)
+	}
+
+	// Cloudflare flattens a CNAME at the zone apex itself, so the hostname isn't resolved by routeflare
+	want := []string{"CNAME lb.elb.example.net"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+}
+
+func TestPublishSourceRemovesRecordsWithoutRecordNames(t *testing.T) {
+	c, dnsProvider, zoneID, _ := newInMemoryTestController(t)
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hostname": "app.example.com",
+			"source": map[string]interface{}{
+				"addresses": []interface{}{"192.0.2.1"},
+			},
+		},
+	}}
+	obj.SetAPIVersion("routeflare.io/v1alpha1")
+	obj.SetKind(kubernetes.RecordKind)
+	obj.SetNamespace("default")
+	obj.SetName("app")
+
+	if err := c.publishSource(obj, parseRecordSpec(obj), false); err != nil {
+		t.Fatalf("publishSource: %v", err)
+	}
+	if records, _ := dnsProvider.ListRecords(context.Background(), zoneID); len(records) != 1 {
+		t.Fatalf("got records %+v, want one", records)
+	}
+
+	// The record loses its hostname, so it has no record names at all
+	unstructured.RemoveNestedField(obj.Object, "spec", "hostname")
+	if err := c.publishSource(obj, parseRecordSpec(obj), false); err == nil {
+		t.Fatal("publishSource succeeded without a hostname, want an error")
+	}
+	if records, _ := dnsProvider.ListRecords(context.Background(), zoneID); len(records) != 0 {
+		t.Errorf("got records %+v, want none", records)
+	}
+	if len(c.trackedRoutes) != 0 {
+		t.Errorf("got %d tracked record names, want none", len(c.trackedRoutes))
+	}
+}
+
+func TestProcessSourceDryRunMakesNoWrites(t *testing.T) {
+	server := cloudflaretest.NewServer()
+	t.Cleanup(server.Close)
+	zoneID := server.AddZone("example.com")
+	server.AddRecord(zoneID, cf.DNSRecord{Type: "A", Name: "app.example.com", Content: "198.51.100.1", TTL: 1, Comment: "record-owner-id=routeflare"})
+	dnsProvider, err := cloudflare.NewClient("test-token", cloudflare.WithBaseURL(server.URL), cloudflare.WithDryRun())
+	if err != nil {
+		t.Fatalf("creating Cloudflare client: %v", err)
+	}
+
+	// Without a Kubernetes client, any status or finalizer write would panic
+	c, recorder := newTestControllerWithProvider(t, dnsProvider)
+	c.cfg.DryRun = true
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hostname": "app.example.com",
+			"source": map[string]interface{}{
+				"addresses": []interface{}{"192.0.2.1"},
+			},
+		},
+	}}
+	obj.SetAPIVersion("routeflare.io/v1alpha1")
+	obj.SetKind(kubernetes.RecordKind)
+	obj.SetNamespace("default")
+	obj.SetName("app")
+	obj.SetFinalizers([]string{c.cfg.FinalizerName()})
+
+	c.processSource(obj, false)
+	if err := c.processSourceDeletion(obj); err != nil {
+		t.Fatalf("processSourceDeletion: %v", err)
+	}
+	obj.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	c.processSource(obj, false)
+
+	if writes := server.WriteRequests(); writes != 0 {
+		t.Errorf("got %d write requests in dry run mode, want none", writes)
+	}
+	want := []string{"A 198.51.100.1"}
+	if got := zoneContents(server, zoneID); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+	for _, event := range drainEvents(recorder) {
+		if !strings.Contains(event, "[dry run]") {
+			t.Errorf("got Event %q in dry run mode, want it marked as a dry run", event)
+		}
+	}
+}
+
+func TestPublishAddressesFailureKeepsPublishedAddresses(t *testing.T) {
+	c, server, zoneID, _ := newTestController(t)
+	server.AddRecord(zoneID, cf.DNSRecord{
+		Type:    "A",
+		Name:    "app.example.com",
+		Content: "198.51.100.1",
+		TTL:     1,
+		Comment: "record-owner-id=someone-else",
+	})
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A", ttl: 1}
+
+	err := c.publishAddresses(testRoute(), "app.example.com", opts, []string{"192.0.2.1"}, "", &trackedRoute{})
+	if !errors.Is(err, provider.ErrOwnershipConflict) {
+		t.Fatalf("publishAddresses error = %v, want an ownership conflict", err)
+	}
+
+	// The record name is tracked so it's retried, but nothing was published for the status to report
+	if _, ok := c.trackedRoutes[trackingKey(testRoute(), "app.example.com")]; !ok {
+		t.Error("record name isn't tracked after failing to publish it")
+	}
+	if records := c.getStatusRecords(objectKey(testRoute())); len(records) != 0 {
+		t.Errorf("got status records %+v, want none", records)
+	}
+}
+
+// failingDeletes is a DNS provider whose deletes fail
+type failingDeletes struct {
+	provider.Provider
+}
+
+func (failingDeletes) DeleteRecord(context.Context, string, provider.Record) ([]provider.Record, error) {
+	return nil, errors.New("API unavailable")
+}
+
+func TestProcessSourceDeleteFailureRecordsOneEvent(t *testing.T) {
+	c, dnsProvider, _, recorder := newInMemoryTestController(t)
+	c.cfg.DryRun = true // Skips writing the record's status, which needs a Kubernetes client
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hostname": "app.example.com",
+			"source": map[string]interface{}{
+				"addresses": []interface{}{"192.0.2.1"},
+			},
+		},
+	}}
+	obj.SetAPIVersion("routeflare.io/v1alpha1")
+	obj.SetKind(kubernetes.RecordKind)
+	obj.SetNamespace("default")
+	obj.SetName("app")
+	c.processSource(obj, false)
+	drainEvents(recorder)
+
+	// The record's hostname changes, and deleting the records of the old one fails
+	c.dnsProvider = failingDeletes{Provider: dnsProvider}
+	_ = unstructured.SetNestedField(obj.Object, "www.example.com", "spec", "hostname")
+	c.processSource(obj, false)
+
+	var warnings []string
+	for _, event := range drainEvents(recorder) {
+		if strings.HasPrefix(event, "Warning") {
+			warnings = append(warnings, event)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "API unavailable") {
+		t.Errorf("got Warning Events %v, want one for the failed delete", warnings)
+	}
+}
+
+func TestProcessSourceDeletionFailureKeepsTracking(t *testing.T) {
+	c, dnsProvider, zoneID, _ := newInMemoryTestController(t)
+	opts := recordOptions{contentMode: "gateway-address", recordType: "A", ttl: 1}
+	if err := c.publishAddresses(testRoute(), "app.example.com", opts, []string{"192.0.2.1"}, "", &trackedRoute{}); err != nil {
+		t.Fatalf("publishAddresses: %v", err)
+	}
+
+	c.dnsProvider = failingDeletes{Provider: dnsProvider}
+	if err := c.processSourceDeletion(testRoute()); err == nil {
+		t.Fatal("processSourceDeletion succeeded, want the delete error")
+	}
+	if len(c.trackedRoutes) != 1 {
+		t.Fatalf("got %d tracked record names after failing to delete them, want 1", len(c.trackedRoutes))
+	}
+
+	// The reconciliation job retries deleting the records of a source that no longer exists
+	c.dnsProvider = dnsProvider
+	if err := c.deleteTrackedRecordNames(objectKey(testRoute()), nil, nil); err != nil {
+		t.Fatalf("deleteTrackedRecordNames: %v", err)
+	}
+	if records, _ := dnsProvider.ListRecords(context.Background(), zoneID); len(records) != 0 {
+		t.Errorf("got records %+v, want none", records)
+	}
+	if len(c.trackedRoutes) != 0 {
+		t.Errorf("got %d tracked record names, want none", len(c.trackedRoutes))
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// statusRefreshInterval is how often a source's status annotation is rewritten while nothing but its lastSyncTime changes
+const statusRefreshInterval = time.Hour
+
+// sourceStatus is the DNS sync status routeflare writes to a source's status annotation
+type sourceStatus struct {
+	Records      []statusRecord `json:"records"`
+	LastSyncTime string         `json:"lastSyncTime,omitempty"`
+	LastError    string         `json:"lastError,omitempty"`
+}
+
+// statusRecord is a DNS record published for a source
+type statusRecord struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Zone    string   `json:"zone"`
+	Content []string `json:"content"`
+}
+
+// statusAnnotationKey returns the key of the annotation a source's DNS sync status is written to
+func (c *Controller) statusAnnotationKey() string {
+	return c.cfg.AnnotationPrefix + "status"
+}
+
+// updateSourceStatus reports the records published for a source, and the result of publishing them, in its status annotation
+// The annotation is only written when the records or error change, or its lastSyncTime is older than statusRefreshInterval
+func (c *Controller) updateSourceStatus(obj *unstructured.Unstructured, publishErr error) {
+	if c.cfg.DryRun {
+		return
+	}
+
+	key := c.statusAnnotationKey()
+	var existing sourceStatus
+	existingValue, hasExisting := obj.GetAnnotations()[key]
+	if hasExisting {
+		if err := json.Unmarshal([]byte(existingValue), &existing); err != nil {
+			hasExisting = false
+		}
+	}
+
+	status := sourceStatus{
+		Records:      c.getStatusRecords(objectKey(obj)),
+		LastSyncTime: existing.LastSyncTime,
+	}
+	now := time.Now().UTC()
+	if publishErr != nil {
+		status.LastError = strings.ReplaceAll(publishErr.Error(), "\n", "; ")
+	} else {
+		status.LastSyncTime = now.Format(time.RFC3339)
+	}
+
+	if hasExisting && reflect.DeepEqual(status.Records, existing.Records) && status.LastError == existing.LastError {
+		lastSync, err := time.Parse(time.RFC3339, existing.LastSyncTime)
+		if publishErr != nil || (err == nil && now.Sub(lastSync) < statusRefreshInterval) {
+			return // Unchanged
+		}
+	}
+
+	value, err := json.Marshal(status)
+	if err != nil {
+		slogs.Logr.Error("encoding status annotation", "source", objectKey(obj), "error", err)
+		return
+	}
+	if err := c.k8sClient.AnnotateSource(c.ctx, obj, key, string(value)); err != nil {
+		slogs.Logr.Error("writing status annotation", "source", objectKey(obj), "error", err)
+	}
+}
+
+// getStatusRecords returns the records tracked for a source, sorted by name and type
+func (c *Controller) getStatusRecords(sourceKey string) []statusRecord {
+	c.routesMutex.RLock()
+	defer c.routesMutex.RUnlock()
+
+	records := []statusRecord{}
+	for _, tracked := range c.trackedRoutes {
+		if tracked.sourceKey() != sourceKey {
+			continue
+		}
+
+		if tracked.cnameTarget != "" {
+			records = append(records, statusRecord{
+				Name:    tracked.recordName,
+				Type:    "CNAME",
+				Zone:    tracked.zoneName,
+				Content: []string{tracked.cnameTarget},
+			})
+			continue
+		}
+
+		var ipv4s, ipv6s []string
+		for _, ip := range tracked.lastIPs {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+				ipv6s = append(ipv6s, ip)
+			} else {
+				ipv4s = append(ipv4s, ip)
+			}
+		}
+		if len(ipv4s) > 0 {
+			records = append(records, statusRecord{Name: tracked.recordName, Type: "A", Zone: tracked.zoneName, Content: ipv4s})
+		}
+		if len(ipv6s) > 0 {
+			records = append(records, statusRecord{Name: tracked.recordName, Type: "AAAA", Zone: tracked.zoneName, Content: ipv6s})
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+	return records
+}
+
+// isRouteflareMetadataUpdate returns true if the only change between two versions of a source is to its status annotation
+// or this instance's finalizer
+// routeflare writes both itself, so publishing the source again for them would loop
+func (c *Controller) isRouteflareMetadataUpdate(oldObj, newObj *unstructured.Unstructured) bool {
+	key := c.statusAnnotationKey()
+	if oldObj.GetAnnotations()[key] == newObj.GetAnnotations()[key] && c.hasFinalizer(oldObj) == c.hasFinalizer(newObj) {
+		return false
+	}
+
+	oldCopy, newCopy := oldObj.DeepCopy(), newObj.DeepCopy()
+	for _, obj := range []*unstructured.Unstructured{oldCopy, newCopy} {
+		annotations := obj.GetAnnotations()
+		delete(annotations, key)
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+		finalizers := slices.DeleteFunc(obj.GetFinalizers(), func(finalizer string) bool {
+			return finalizer == c.cfg.FinalizerName()
+		})
+		if len(finalizers) == 0 {
+			finalizers = nil
+		}
+		obj.SetFinalizers(finalizers)
+		obj.SetResourceVersion("")
+		obj.SetManagedFields(nil)
+	}
+	return reflect.DeepEqual(oldCopy.Object, newCopy.Object)
+}
@@ -8,7 +8,86 @@ import (
 )
 
 // GetGatewayAddresses extracts IP addresses from a Gateway's status.addresses
-func GetGatewayAddresses(gateway *unstructured.Unstructured, recordType string) ([]string, error) {
+// Only the first address of each IP family is returned unless allAddresses is true
+func GetGatewayAddresses(gateway *unstructured.Unstructured, recordType string, allAddresses bool) ([]string, error) {
+	addresses, err := getStatusAddresses(gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, addrMap := range addresses {
+		addrValue, found, err := unstructured.NestedString(addrMap, "value")
+		if !found || err != nil {
+			continue
+		}
+
+		// Check if it's a valid IP address
+		if net.ParseIP(addrValue) == nil {
+			continue
+		}
+		ips = append(ips, addrValue)
+	}
+
+	return selectIPs(ips, recordType, allAddresses, "gateway status.addresses")
+}
+
+// GetGatewayHostname returns the first address of type Hostname from a Gateway's status.addresses
+// Gateways implemented by cloud load balancers often only report a hostname rather than IP addresses
+func GetGatewayHostname(gateway *unstructured.Unstructured) (string, bool) {
+	addresses, err := getStatusAddresses(gateway)
+	if err != nil {
+		return "", false
+	}
+
+	for _, addrMap := range addresses {
+		addrType, _, _ := unstructured.NestedString(addrMap, "type")
+		if addrType != "Hostname" {
+			continue
+		}
+
+		addrValue, found, err := unstructured.NestedString(addrMap, "value")
+		if !found || err != nil || addrValue == "" {
+			continue
+		}
+		return addrValue, true
+	}
+
+	return "", false
+}
+
+// GetListenerHostnames returns the distinct hostnames of a Gateway's listeners, in the order they are listed
+// Listeners without a hostname match any hostname, so they are skipped
+func GetListenerHostnames(gateway *unstructured.Unstructured) ([]string, error) {
+	listeners, found, err := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if !found || err != nil {
+		return nil, fmt.Errorf("gateway has no spec.listeners or error accessing it: %w", err)
+	}
+
+	var hostnames []string
+	seen := make(map[string]bool)
+	for _, listenerInterface := range listeners {
+		listener, ok := listenerInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		hostname, found, err := unstructured.NestedString(listener, "hostname")
+		if !found || err != nil || hostname == "" || seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+		hostnames = append(hostnames, hostname)
+	}
+
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("gateway has no listeners with a hostname")
+	}
+	return hostnames, nil
+}
+
+// getStatusAddresses returns the entries of a Gateway's status.addresses
+func getStatusAddresses(gateway *unstructured.Unstructured) ([]map[string]interface{}, error) {
 	status, found, err := unstructured.NestedMap(gateway.Object, "status")
 	if !found || err != nil {
 		return nil, fmt.Errorf("gateway has no status or error accessing it: %w", err)
@@ -19,57 +98,69 @@ func GetGatewayAddresses(gateway *unstructured.Unstructured, recordType string)
 		return nil, fmt.Errorf("gateway has no status.addresses or error accessing it: %w", err)
 	}
 
-	var ipv4Addrs []string
-	var ipv6Addrs []string
-
+	result := make([]map[string]interface{}, 0, len(addresses))
 	for _, addrInterface := range addresses {
 		addrMap, ok := addrInterface.(map[string]interface{})
 		if !ok {
 			continue
 		}
+		result = append(result, addrMap)
+	}
+	return result, nil
+}
 
-		addrValue, found, err := unstructured.NestedString(addrMap, "value")
-		if !found || err != nil {
-			continue
-		}
+// selectIPs filters IP addresses down to the families used by a record type
+// source describes where the addresses came from, for error messages
+func selectIPs(ips []string, recordType string, allAddresses bool, source string) ([]string, error) {
+	var ipv4Addrs []string
+	var ipv6Addrs []string
 
-		// Check if it's a valid IP address
-		ip := net.ParseIP(addrValue)
+	for _, addr := range ips {
+		ip := net.ParseIP(addr)
 		if ip == nil {
 			continue
 		}
 
 		if ip.To4() != nil {
-			ipv4Addrs = append(ipv4Addrs, addrValue)
+			ipv4Addrs = append(ipv4Addrs, addr)
 		} else {
-			ipv6Addrs = append(ipv6Addrs, addrValue)
+			ipv6Addrs = append(ipv6Addrs, addr)
 		}
 	}
 
+	if !allAddresses {
+		ipv4Addrs = firstAddress(ipv4Addrs)
+		ipv6Addrs = firstAddress(ipv6Addrs)
+	}
+
 	switch recordType {
 	case "A":
 		if len(ipv4Addrs) == 0 {
-			return nil, fmt.Errorf("no IPv4 addresses found in gateway status.addresses")
+			return nil, fmt.Errorf("no IPv4 addresses found in %s", source)
 		}
-		return []string{ipv4Addrs[0]}, nil
+		return ipv4Addrs, nil
 	case "AAAA":
 		if len(ipv6Addrs) == 0 {
-			return nil, fmt.Errorf("no IPv6 addresses found in gateway status.addresses")
+			return nil, fmt.Errorf("no IPv6 addresses found in %s", source)
 		}
-		return []string{ipv6Addrs[0]}, nil
+		return ipv6Addrs, nil
 	case "A/AAAA":
 		var result []string
-		if len(ipv4Addrs) > 0 {
-			result = append(result, ipv4Addrs[0])
-		}
-		if len(ipv6Addrs) > 0 {
-			result = append(result, ipv6Addrs[0])
-		}
+		result = append(result, ipv4Addrs...)
+		result = append(result, ipv6Addrs...)
 		if len(result) == 0 {
-			return nil, fmt.Errorf("no IP addresses found in gateway status.addresses")
+			return nil, fmt.Errorf("no IP addresses found in %s", source)
 		}
 		return result, nil
 	default:
 		return nil, fmt.Errorf("unsupported record type: %s", recordType)
 	}
 }
+
+// firstAddress returns a slice containing only the first address, if there is one
+func firstAddress(addrs []string) []string {
+	if len(addrs) == 0 {
+		return addrs
+	}
+	return addrs[:1]
+}
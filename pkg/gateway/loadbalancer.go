@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// GetLoadBalancerAddresses extracts IP addresses from the status.loadBalancer.ingress of a Service or Ingress
+// Only the first address of each IP family is returned unless allAddresses is true
+func GetLoadBalancerAddresses(obj *unstructured.Unstructured, recordType string, allAddresses bool) ([]string, error) {
+	ingresses, err := getLoadBalancerIngresses(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, ingress := range ingresses {
+		ip, found, err := unstructured.NestedString(ingress, "ip")
+		if !found || err != nil || net.ParseIP(ip) == nil {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+
+	return selectIPs(ips, recordType, allAddresses, "status.loadBalancer.ingress")
+}
+
+// GetLoadBalancerHostname returns the first hostname from the status.loadBalancer.ingress of a Service or Ingress
+// Cloud load balancers, such as an AWS ELB, often only report a hostname rather than IP addresses
+func GetLoadBalancerHostname(obj *unstructured.Unstructured) (string, bool) {
+	ingresses, err := getLoadBalancerIngresses(obj)
+	if err != nil {
+		return "", false
+	}
+
+	for _, ingress := range ingresses {
+		hostname, found, err := unstructured.NestedString(ingress, "hostname")
+		if !found || err != nil || hostname == "" {
+			continue
+		}
+		return hostname, true
+	}
+
+	return "", false
+}
+
+// getLoadBalancerIngresses returns the entries of an object's status.loadBalancer.ingress
+func getLoadBalancerIngresses(obj *unstructured.Unstructured) ([]map[string]interface{}, error) {
+	ingresses, found, err := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if !found || err != nil {
+		return nil, fmt.Errorf("%s has no status.loadBalancer.ingress or error accessing it: %w", obj.GetKind(), err)
+	}
+
+	result := make([]map[string]interface{}, 0, len(ingresses))
+	for _, ingressInterface := range ingresses {
+		ingress, ok := ingressInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		result = append(result, ingress)
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const apiGroup = "gateway.networking.k8s.io"
+
+// ParentRef is a route's reference to a parent Gateway
+type ParentRef struct {
+	Namespace   string
+	Name        string
+	SectionName string // The name of the Gateway's listener the route attaches to, or empty for any listener
+	Port        int64  // The port of the Gateway's listener the route attaches to, or 0 for any listener
+}
+
+// Key returns the "namespace/name" key of the referenced Gateway
+func (r ParentRef) Key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// GetParentRefs returns the references to Gateways in a route's spec.parentRefs, in order
+// Other kinds of parents, such as Services used by a service mesh, or ListenerSets, are skipped
+func GetParentRefs(route *unstructured.Unstructured) []ParentRef {
+	parents, _, err := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if err != nil {
+		return nil
+	}
+
+	var refs []ParentRef
+	for _, parentInterface := range parents {
+		parentRef, ok := parentInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// Group and kind default to a Gateway when they are unset
+		group, found, _ := unstructured.NestedString(parentRef, "group")
+		if found && group != apiGroup {
+			continue
+		}
+		kind, found, _ := unstructured.NestedString(parentRef, "kind")
+		if found && kind != "Gateway" {
+			continue
+		}
+
+		ref := ParentRef{}
+		ref.Name, _, _ = unstructured.NestedString(parentRef, "name")
+		if ref.Name == "" {
+			continue
+		}
+		ref.Namespace, _, _ = unstructured.NestedString(parentRef, "namespace")
+		if ref.Namespace == "" {
+			ref.Namespace = route.GetNamespace() // Default to the route's namespace
+		}
+		ref.SectionName, _, _ = unstructured.NestedString(parentRef, "sectionName")
+		ref.Port, _, _ = unstructured.NestedInt64(parentRef, "port")
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// GetGatewayRef returns the Gateway referenced by a RouteflareRecord's spec.source.gatewayRef, if it has one
+func GetGatewayRef(record *unstructured.Unstructured) (ParentRef, bool) {
+	gatewayRef, found, err := unstructured.NestedMap(record.Object, "spec", "source", "gatewayRef")
+	if !found || err != nil {
+		return ParentRef{}, false
+	}
+
+	ref := ParentRef{}
+	ref.Name, _, _ = unstructured.NestedString(gatewayRef, "name")
+	if ref.Name == "" {
+		return ParentRef{}, false
+	}
+	ref.Namespace, _, _ = unstructured.NestedString(gatewayRef, "namespace")
+	if ref.Namespace == "" {
+		ref.Namespace = record.GetNamespace() // Default to the record's namespace
+	}
+	ref.SectionName, _, _ = unstructured.NestedString(gatewayRef, "sectionName")
+	return ref, true
+}
+
+// HasListener returns true if a Gateway has a listener a parentRef can attach to
+// A parentRef without a sectionName or port can attach to any of the Gateway's listeners
+func HasListener(gateway *unstructured.Unstructured, ref ParentRef) bool {
+	if ref.SectionName == "" && ref.Port == 0 {
+		return true
+	}
+
+	listeners, _, err := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if err != nil {
+		return false
+	}
+
+	for _, listenerInterface := range listeners {
+		listener, ok := listenerInterface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(listener, "name")
+		if ref.SectionName != "" && name != ref.SectionName {
+			continue
+		}
+		port, _, _ := unstructured.NestedInt64(listener, "port")
+		if ref.Port != 0 && port != ref.Port {
+			continue
+		}
+		return true
+	}
+	return false
+}
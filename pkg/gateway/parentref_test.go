@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testRoute returns an HTTPRoute in the default namespace with the given parentRefs
+func testRoute(parentRefs ...interface{}) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"parentRefs": parentRefs},
+	}}
+	route.SetKind("HTTPRoute")
+	route.SetNamespace("default")
+	route.SetName("app")
+	return route
+}
+
+func TestGetParentRefs(t *testing.T) {
+	tests := []struct {
+		name       string
+		parentRefs []interface{}
+		want       []ParentRef
+	}{
+		{
+			name:       "group and kind default to a Gateway",
+			parentRefs: []interface{}{map[string]interface{}{"name": "public"}},
+			want:       []ParentRef{{Namespace: "default", Name: "public"}},
+		},
+		{
+			name: "explicit Gateway in another namespace",
+			parentRefs: []interface{}{map[string]interface{}{
+				"group": "gateway.networking.k8s.io", "kind": "Gateway", "namespace": "infra", "name": "public",
+			}},
+			want: []ParentRef{{Namespace: "infra", Name: "public"}},
+		},
+		{
+			name: "other kinds and groups are skipped",
+			parentRefs: []interface{}{
+				map[string]interface{}{"group": "", "kind": "Service", "name": "mesh"},
+				map[string]interface{}{"group": "gateway.networking.x-k8s.io", "kind": "XListenerSet", "name": "listeners"},
+				map[string]interface{}{"group": "example.com", "kind": "Gateway", "name": "other"},
+				map[string]interface{}{"name": "public"},
+			},
+			want: []ParentRef{{Namespace: "default", Name: "public"}},
+		},
+		{
+			name: "sectionName and port",
+			parentRefs: []interface{}{
+				map[string]interface{}{"name": "public", "sectionName": "https"},
+				map[string]interface{}{"name": "internal", "port": int64(8443)},
+			},
+			want: []ParentRef{
+				{Namespace: "default", Name: "public", SectionName: "https"},
+				{Namespace: "default", Name: "internal", Port: 8443},
+			},
+		},
+		{
+			name:       "no name",
+			parentRefs: []interface{}{map[string]interface{}{"kind": "Gateway"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetParentRefs(testRoute(tt.parentRefs...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetParentRefs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasListener(t *testing.T) {
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(80)},
+				map[string]interface{}{"name": "https", "port": int64(443)},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name string
+		ref  ParentRef
+		want bool
+	}{
+		{name: "any listener", ref: ParentRef{}, want: true},
+		{name: "matching sectionName", ref: ParentRef{SectionName: "https"}, want: true},
+		{name: "unknown sectionName", ref: ParentRef{SectionName: "grpc"}},
+		{name: "matching port", ref: ParentRef{Port: 80}, want: true},
+		{name: "unknown port", ref: ParentRef{Port: 8080}},
+		{name: "matching sectionName and port", ref: ParentRef{SectionName: "https", Port: 443}, want: true},
+		{name: "sectionName on another listener's port", ref: ParentRef{SectionName: "https", Port: 80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasListener(gateway, tt.ref); got != tt.want {
+				t.Errorf("HasListener(%+v) = %v, want %v", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
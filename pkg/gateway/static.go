@@ -0,0 +1,7 @@
+package gateway
+
+// GetStaticAddresses filters a list of static IP addresses, such as a RouteflareRecord's spec.source.addresses,
+// down to the valid addresses of the families used by a record type
+func GetStaticAddresses(addresses []string, recordType string) ([]string, error) {
+	return selectIPs(addresses, recordType, true, "spec.source.addresses")
+}
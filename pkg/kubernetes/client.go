@@ -2,46 +2,113 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"slices"
 
+	"github.com/chia-network/go-modules/pkg/slogs"
+	"github.com/starttoaster/routeflare/pkg/gateway"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/dynamic/dynamiclister"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/homedir"
 )
 
+const (
+	gatewayAPIGroup = "gateway.networking.k8s.io"
+	recordAPIGroup  = "routeflare.io"
+)
+
+// RecordKind is the kind of the RouteflareRecord custom resource, which declares a DNS record that isn't tied to a route
+const RecordKind = "RouteflareRecord"
+
+// recordVersions are the versions of the RouteflareRecord custom resource to try, in order of preference
+var recordVersions = []string{"v1alpha1"}
+
+// gatewayVersions are the versions of the Gateway resource to try, in order of preference
+var gatewayVersions = []string{"v1", "v1beta1"}
+
+// routeResource describes a kind of Gateway API route that routeflare publishes DNS records for
+type routeResource struct {
+	kind     string
+	resource string
+	versions []string // Versions to try, in order of preference
+}
+
+// GatewayIndex is the name of the route and RouteflareRecord informer index that maps a Gateway's "namespace/name" key
+// to the routes attached to it, and the RouteflareRecords referencing it
+const GatewayIndex = "gateway"
+
+// routeResources are the kinds of routes routeflare watches, when their CRDs are installed
+var routeResources = []routeResource{
+	{kind: "HTTPRoute", resource: "httproutes", versions: []string{"v1"}},
+	{kind: "GRPCRoute", resource: "grpcroutes", versions: []string{"v1", "v1alpha2"}},
+	// TLSRoute is only in Gateway API's experimental channel, so its version depends on the release that's installed
+	{kind: "TLSRoute", resource: "tlsroutes", versions: []string{"v1", "v1alpha3", "v1alpha2"}},
+}
+
 var (
-	httpRouteGVR = schema.GroupVersionResource{
-		Group:    "gateway.networking.k8s.io",
+	serviceGVR = schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "services",
+	}
+
+	ingressGVR = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
 		Version:  "v1",
-		Resource: "httproutes",
+		Resource: "ingresses",
 	}
 
-	gatewayGVR = schema.GroupVersionResource{
-		Group:    "gateway.networking.k8s.io",
+	namespaceGVR = schema.GroupVersionResource{
+		Group:    "",
 		Version:  "v1",
-		Resource: "gateways",
+		Resource: "namespaces",
 	}
 )
 
+// Scope limits which sources are watched, so several instances of routeflare can each manage their own sources
+type Scope struct {
+	Namespaces        []string // Namespaces to watch, or every namespace if empty
+	ExcludeNamespaces []string // Namespaces not to watch
+	NamespaceSelector string   // Label selector for the namespaces to watch
+	LabelSelector     string   // Label selector for the sources to watch
+}
+
 // Client wraps Kubernetes clients
 type Client struct {
 	dynamicClient     dynamic.Interface
 	clientset         kubernetes.Interface
-	informerFactory   dynamicinformer.DynamicSharedInformerFactory
-	httpRouteInformer cache.SharedInformer
+	informerFactories []dynamicinformer.DynamicSharedInformerFactory
+	sourceFactories   []dynamicinformer.DynamicSharedInformerFactory // One for each namespace in the namespace list, or one for every namespace
+	routeKinds        []string
+	sourceInformers   map[string][]cache.SharedIndexInformer // kind -> informer from each source factory, for each kind of source being watched
+	gatewayInformer   cache.SharedInformer                   // Every Gateway, for looking up the parents of routes, or nil if the Gateway CRD isn't installed
+	gatewayLister     dynamiclister.Lister                   // Lists from gatewayInformer, or nil if the Gateway CRD isn't installed
+	recordGVR         schema.GroupVersionResource
+	namespaceInformer cache.SharedInformer // The namespaces matching the namespace selector, or nil if there is none
+	namespaces        map[string]bool      // The namespaces to watch, or nil to watch every namespace
+	eventRecorder     record.EventRecorder
+	sourceGVRs        map[string]schema.GroupVersionResource // kind -> resource, for writing to sources
 }
 
-// NewClient creates a new Kubernetes client
-func NewClient(kubeconfigPath string) (*Client, error) {
+// NewClient creates a new Kubernetes client that watches the sources in scope
+func NewClient(kubeconfigPath string, scope Scope) (*Client, error) {
 	config, err := getKubernetesConfig(kubeconfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("error getting Kubernetes config: %w", err)
@@ -63,18 +130,164 @@ func NewClient(kubeconfigPath string) (*Client, error) {
 		return nil, fmt.Errorf("error connecting to Kubernetes cluster: %w", err)
 	}
 
-	// Create informer factory
+	// Create informer factories
+	// Routes may be attached to Gateways in any namespace, so Gateways are always watched in every namespace,
+	// while sources are only watched in the listed namespaces, when their labels match the label selector and their namespace isn't excluded
 	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	informerFactories := []dynamicinformer.DynamicSharedInformerFactory{informerFactory}
+	var sourceFactories []dynamicinformer.DynamicSharedInformerFactory
+	switch {
+	case len(scope.Namespaces) > 0:
+		// A list-watch can only be limited to one namespace, so each listed namespace gets its own factory
+		for _, namespace := range scope.Namespaces {
+			sourceFactories = append(sourceFactories, dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, namespace, scope.tweakListOptions))
+		}
+		informerFactories = append(informerFactories, sourceFactories...)
+	case scope.LabelSelector != "" || len(scope.ExcludeNamespaces) > 0:
+		sourceFactories = []dynamicinformer.DynamicSharedInformerFactory{
+			dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, metav1.NamespaceAll, scope.tweakListOptions),
+		}
+		informerFactories = append(informerFactories, sourceFactories...)
+	default:
+		sourceFactories = []dynamicinformer.DynamicSharedInformerFactory{informerFactory}
+	}
 
-	// Create HTTPRoute informer
-	httpRouteInformer := informerFactory.ForResource(httpRouteGVR).Informer()
+	// Create an event recorder, for recording the outcome of publishing a source's DNS records on the source
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "routeflare"})
 
-	return &Client{
+	client := &Client{
 		dynamicClient:     dynamicClient,
 		clientset:         clientset,
-		informerFactory:   informerFactory,
-		httpRouteInformer: httpRouteInformer,
-	}, nil
+		informerFactories: informerFactories,
+		sourceFactories:   sourceFactories,
+		sourceInformers:   make(map[string][]cache.SharedIndexInformer),
+		eventRecorder:     eventRecorder,
+		sourceGVRs: map[string]schema.GroupVersionResource{
+			"Service": serviceGVR,
+			"Ingress": ingressGVR,
+		},
+	}
+
+	client.sourceInformers["Service"] = client.newSourceInformers(serviceGVR)
+	client.sourceInformers["Ingress"] = client.newSourceInformers(ingressGVR)
+
+	// Create the Gateway informers if the Gateway CRD is installed, since listing a resource that isn't served would keep the caches from syncing
+	gatewayGVR, found, err := client.discoverResource(gatewayAPIGroup, "gateways", gatewayVersions)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering Gateway API version: %w", err)
+	}
+	if found {
+		slogs.Logr.Info("Watching Gateways", "version", gatewayGVR.Version)
+		gatewayInformer := informerFactory.ForResource(gatewayGVR).Informer()
+		client.gatewayInformer = gatewayInformer
+		client.gatewayLister = dynamiclister.New(gatewayInformer.GetIndexer(), gatewayGVR)
+		client.sourceInformers["Gateway"] = client.newSourceInformers(gatewayGVR)
+		client.sourceGVRs["Gateway"] = gatewayGVR
+	} else {
+		slogs.Logr.Warn("Gateway CRD is not installed, not watching Gateways")
+	}
+
+	// Namespaces can't be selected by their labels in a list-watch, so the namespace selector is applied by WatchesNamespace
+	if len(scope.Namespaces) > 0 {
+		client.namespaces = make(map[string]bool)
+		for _, namespace := range scope.Namespaces {
+			client.namespaces[namespace] = true
+		}
+	}
+	if scope.NamespaceSelector != "" {
+		namespaceInformerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, metav1.NamespaceAll, func(options *metav1.ListOptions) {
+			options.LabelSelector = scope.NamespaceSelector
+		})
+		client.informerFactories = append(client.informerFactories, namespaceInformerFactory)
+		client.namespaceInformer = namespaceInformerFactory.ForResource(namespaceGVR).Informer()
+	}
+
+	// Create an informer for each kind of route whose CRD is installed
+	for _, route := range routeResources {
+		gvr, found, err := client.discoverResource(gatewayAPIGroup, route.resource, route.versions)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering %s API version: %w", route.kind, err)
+		}
+		if !found {
+			slogs.Logr.Warn("Route CRD is not installed, not watching it", "kind", route.kind)
+			continue
+		}
+
+		slogs.Logr.Info("Watching routes", "kind", route.kind, "version", gvr.Version)
+		informers := client.newSourceInformers(gvr)
+		for _, informer := range informers {
+			if err := informer.AddIndexers(cache.Indexers{GatewayIndex: gatewayIndexFunc}); err != nil {
+				return nil, fmt.Errorf("error adding %s gateway index: %w", route.kind, err)
+			}
+		}
+		client.routeKinds = append(client.routeKinds, route.kind)
+		client.sourceInformers[route.kind] = informers
+		client.sourceGVRs[route.kind] = gvr
+	}
+
+	// Create an informer for RouteflareRecords if their CRD is installed
+	gvr, found, err := client.discoverResource(recordAPIGroup, "routeflarerecords", recordVersions)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering %s API version: %w", RecordKind, err)
+	}
+	if found {
+		slogs.Logr.Info("Watching records", "kind", RecordKind, "version", gvr.Version)
+		client.recordGVR = gvr
+		client.sourceGVRs[RecordKind] = gvr
+		informers := client.newSourceInformers(gvr)
+		for _, informer := range informers {
+			if err := informer.AddIndexers(cache.Indexers{GatewayIndex: gatewayIndexFunc}); err != nil {
+				return nil, fmt.Errorf("error adding %s gateway index: %w", RecordKind, err)
+			}
+		}
+		client.sourceInformers[RecordKind] = informers
+	} else {
+		slogs.Logr.Warn("RouteflareRecord CRD is not installed, not watching it")
+	}
+
+	return client, nil
+}
+
+// newSourceInformers returns the informers for a kind of source from each of the source informer factories
+func (c *Client) newSourceInformers(gvr schema.GroupVersionResource) []cache.SharedIndexInformer {
+	informers := make([]cache.SharedIndexInformer, 0, len(c.sourceFactories))
+	for _, factory := range c.sourceFactories {
+		informers = append(informers, factory.ForResource(gvr).Informer())
+	}
+	return informers
+}
+
+// tweakListOptions limits a source list-watch to the sources with matching labels outside of the excluded namespaces
+func (s Scope) tweakListOptions(options *metav1.ListOptions) {
+	options.LabelSelector = s.LabelSelector
+
+	selectors := make([]fields.Selector, 0, len(s.ExcludeNamespaces))
+	for _, namespace := range s.ExcludeNamespaces {
+		selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", namespace))
+	}
+	options.FieldSelector = fields.AndSelectors(selectors...).String()
+}
+
+// discoverResource finds the first of the given versions of a resource in an API group that the cluster serves
+func (c *Client) discoverResource(group, resource string, versions []string) (schema.GroupVersionResource, bool, error) {
+	for _, version := range versions {
+		resources, err := c.clientset.Discovery().ServerResourcesForGroupVersion(group + "/" + version)
+		if apierrors.IsNotFound(err) {
+			continue // Group version isn't served
+		}
+		if err != nil {
+			return schema.GroupVersionResource{}, false, err
+		}
+
+		for _, apiResource := range resources.APIResources {
+			if apiResource.Name == resource {
+				return schema.GroupVersionResource{Group: group, Version: version, Resource: resource}, true, nil
+			}
+		}
+	}
+	return schema.GroupVersionResource{}, false, nil
 }
 
 // getKubernetesConfig returns Kubernetes config, trying in-cluster first, then kubeconfig
@@ -98,62 +311,190 @@ func getKubernetesConfig(kubeconfigPath string) (*rest.Config, error) {
 	return config, nil
 }
 
-// ListHTTPRoutes lists all HTTPRoutes
-func (c *Client) ListHTTPRoutes(ctx context.Context) ([]*unstructured.Unstructured, error) {
-	httpRouteClient := c.dynamicClient.Resource(httpRouteGVR)
-	list, err := httpRouteClient.Namespace("").List(ctx, metav1.ListOptions{})
+// RouteKinds returns the kinds of routes being watched, which are the ones whose CRDs are installed
+func (c *Client) RouteKinds() []string {
+	return c.routeKinds
+}
+
+// GetGatewayInformer returns the informer for Gateways in every namespace, whether they are in scope or not,
+// or nil if the Gateway CRD isn't installed
+func (c *Client) GetGatewayInformer() cache.SharedInformer {
+	return c.gatewayInformer
+}
+
+// GetSourceInformers returns the informers for each kind of source being watched, which are routes, Gateways, Services,
+// Ingresses, and RouteflareRecords, leaving out the kinds whose CRDs aren't installed
+// A kind has an informer for each namespace in the namespace list, or a single informer if there is no namespace list
+func (c *Client) GetSourceInformers() map[string][]cache.SharedInformer {
+	sourceInformers := make(map[string][]cache.SharedInformer, len(c.sourceInformers))
+	for kind, informers := range c.sourceInformers {
+		for _, informer := range informers {
+			sourceInformers[kind] = append(sourceInformers[kind], informer)
+		}
+	}
+	return sourceInformers
+}
+
+// PatchRecordStatus applies a merge patch to the status of a RouteflareRecord
+// The patch has no resourceVersion, so it applies on top of changes made since the record was read, such as an added finalizer
+func (c *Client) PatchRecordStatus(ctx context.Context, record *unstructured.Unstructured, status map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
 	if err != nil {
-		return nil, fmt.Errorf("error listing HTTPRoutes: %w", err)
+		return fmt.Errorf("error encoding patch: %w", err)
 	}
 
-	var routes []*unstructured.Unstructured
-	for i := range list.Items {
-		routes = append(routes, &list.Items[i])
+	_, err = c.dynamicClient.Resource(c.recordGVR).Namespace(record.GetNamespace()).Patch(ctx, record.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("error updating %s status: %w", RecordKind, err)
+	}
+	return nil
+}
+
+// GetEventRecorder returns the recorder for Kubernetes Events on sources
+func (c *Client) GetEventRecorder() record.EventRecorder {
+	return c.eventRecorder
+}
+
+// AnnotateSource sets an annotation on a route, Gateway, Service, or Ingress
+func (c *Client) AnnotateSource(ctx context.Context, source *unstructured.Unstructured, key, value string) error {
+	err := c.patchSourceMetadata(ctx, source, map[string]interface{}{
+		"annotations": map[string]string{key: value},
+	})
+	if err != nil {
+		return fmt.Errorf("error annotating %s: %w", source.GetKind(), err)
 	}
+	return nil
+}
 
-	return routes, nil
+// SetSourceFinalizers replaces the finalizers of a source
+// The patch only applies to the version of the source given, so finalizers added by others since then aren't lost
+func (c *Client) SetSourceFinalizers(ctx context.Context, source *unstructured.Unstructured, finalizers []string) error {
+	if finalizers == nil {
+		finalizers = []string{} // A null list would be ignored by the merge patch
+	}
+	err := c.patchSourceMetadata(ctx, source, map[string]interface{}{
+		"finalizers":      finalizers,
+		"resourceVersion": source.GetResourceVersion(),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting %s finalizers: %w", source.GetKind(), err)
+	}
+	return nil
 }
 
-// GetHTTPRouteInformer returns the HTTPRoute informer
-func (c *Client) GetHTTPRouteInformer() cache.SharedInformer {
-	return c.httpRouteInformer
+// GetSource gets the current version of a source from the API server
+func (c *Client) GetSource(ctx context.Context, source *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvr, ok := c.sourceGVRs[source.GetKind()]
+	if !ok {
+		return nil, fmt.Errorf("unknown source kind %s", source.GetKind())
+	}
+	return c.dynamicClient.Resource(gvr).Namespace(source.GetNamespace()).Get(ctx, source.GetName(), metav1.GetOptions{})
 }
 
-// StartInformerFactory starts the informer factory
+// patchSourceMetadata applies a merge patch to the metadata of a source
+func (c *Client) patchSourceMetadata(ctx context.Context, source *unstructured.Unstructured, metadata map[string]interface{}) error {
+	gvr, ok := c.sourceGVRs[source.GetKind()]
+	if !ok {
+		return fmt.Errorf("unknown source kind %s", source.GetKind())
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return fmt.Errorf("error encoding patch: %w", err)
+	}
+
+	_, err = c.dynamicClient.Resource(gvr).Namespace(source.GetNamespace()).Patch(ctx, source.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// GetNamespaceInformer returns the informer for namespaces matching the namespace selector, or nil if there is none
+func (c *Client) GetNamespaceInformer() cache.SharedInformer {
+	return c.namespaceInformer
+}
+
+// WatchesNamespace returns true if sources in a namespace are in scope
+func (c *Client) WatchesNamespace(namespace string) bool {
+	if c.namespaces != nil && !c.namespaces[namespace] {
+		return false
+	}
+	if c.namespaceInformer != nil {
+		_, exists, err := c.namespaceInformer.GetStore().GetByKey(namespace)
+		return exists && err == nil
+	}
+	return true
+}
+
+// StartInformerFactory starts the informer factories
 func (c *Client) StartInformerFactory(stopCh <-chan struct{}) {
-	c.informerFactory.Start(stopCh)
+	for _, informerFactory := range c.informerFactories {
+		informerFactory.Start(stopCh)
+	}
 }
 
-// WaitForCacheSync waits for the HTTPRoute informer cache to sync
+// WaitForCacheSync waits for the source, Gateway, and namespace informer caches to sync
 func (c *Client) WaitForCacheSync(ctx context.Context) bool {
-	return cache.WaitForCacheSync(ctx.Done(), c.httpRouteInformer.HasSynced)
+	var hasSynced []cache.InformerSynced
+	for _, informers := range c.sourceInformers {
+		for _, informer := range informers {
+			hasSynced = append(hasSynced, informer.HasSynced)
+		}
+	}
+	if c.gatewayInformer != nil {
+		hasSynced = append(hasSynced, c.gatewayInformer.HasSynced)
+	}
+	if c.namespaceInformer != nil {
+		hasSynced = append(hasSynced, c.namespaceInformer.HasSynced)
+	}
+	return cache.WaitForCacheSync(ctx.Done(), hasSynced...)
 }
 
-// GetGateway gets a Gateway by namespace and name
-func (c *Client) GetGateway(ctx context.Context, namespace, name string) (*unstructured.Unstructured, error) {
-	gatewayClient := c.dynamicClient.Resource(gatewayGVR)
-	return gatewayClient.Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+// GetGateway gets a Gateway by namespace and name from the informer cache
+// The returned Gateway is shared with the cache, so it must not be modified
+func (c *Client) GetGateway(namespace, name string) (*unstructured.Unstructured, error) {
+	if c.gatewayLister == nil {
+		return nil, fmt.Errorf("the Gateway CRD is not installed")
+	}
+	return c.gatewayLister.Namespace(namespace).Get(name)
 }
 
-// GetHTTPRoute gets an HTTPRoute by namespace and name
-func (c *Client) GetHTTPRoute(ctx context.Context, namespace, name string) (*unstructured.Unstructured, error) {
-	httpRouteClient := c.dynamicClient.Resource(httpRouteGVR)
-	return httpRouteClient.Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+// ListSourcesForGateway lists the routes of every kind in scope in the informer caches that have the Gateway as a parent,
+// and the RouteflareRecords in scope that reference the Gateway
+func (c *Client) ListSourcesForGateway(namespace, name string) ([]*unstructured.Unstructured, error) {
+	var sources []*unstructured.Unstructured
+	for _, kind := range append(slices.Clone(c.routeKinds), RecordKind) {
+		for _, informer := range c.sourceInformers[kind] {
+			objs, err := informer.GetIndexer().ByIndex(GatewayIndex, namespace+"/"+name)
+			if err != nil {
+				return nil, fmt.Errorf("error listing %ss for Gateway: %w", kind, err)
+			}
+			for _, obj := range objs {
+				if source, ok := obj.(*unstructured.Unstructured); ok && c.WatchesNamespace(source.GetNamespace()) {
+					sources = append(sources, source)
+				}
+			}
+		}
+	}
+	return sources, nil
 }
 
-// ExtractHTTPRouteMetadata extracts metadata from an HTTPRoute object
-func ExtractHTTPRouteMetadata(obj runtime.Object) (name, namespace string, annotations map[string]string, err error) {
-	// Try to get metadata directly first
-	meta, ok := obj.(metav1.Object)
-	if ok {
-		return meta.GetName(), meta.GetNamespace(), meta.GetAnnotations(), nil
+// gatewayIndexFunc indexes a route by the "namespace/name" key of each Gateway in its parentRefs,
+// and a RouteflareRecord by the key of the Gateway it references
+func gatewayIndexFunc(obj interface{}) ([]string, error) {
+	route, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
 	}
 
-	// If that fails, try unstructured
-	unstructuredObj, ok := obj.(*unstructured.Unstructured)
-	if !ok {
-		return "", "", nil, fmt.Errorf("could not convert object to unstructured.Unstructured or metav1.Object")
+	if route.GetKind() == RecordKind {
+		if ref, found := gateway.GetGatewayRef(route); found {
+			return []string{ref.Key()}, nil
+		}
+		return nil, nil
 	}
 
-	return unstructuredObj.GetName(), unstructuredObj.GetNamespace(), unstructuredObj.GetAnnotations(), nil
+	var keys []string
+	for _, ref := range gateway.GetParentRefs(route) {
+		keys = append(keys, ref.Key())
+	}
+	return keys, nil
 }
@@ -0,0 +1,227 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+// Provider is an in-memory DNS provider, useful for running the controller without a real DNS backend
+// Like Cloudflare, it matches zone and record names case insensitively, and stores record names lowercased
+type Provider struct {
+	mu      sync.RWMutex
+	zones   map[string]string                     // zone name -> zone ID
+	records map[string]map[string]provider.Record // zone ID -> record ID -> record
+	nextID  int
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// NewProvider creates a new in-memory provider with no zones
+func NewProvider() *Provider {
+	return &Provider{
+		zones:   make(map[string]string),
+		records: make(map[string]map[string]provider.Record),
+	}
+}
+
+// AddZone adds a zone to the provider and returns its ID
+func (p *Provider) AddZone(zoneName string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zoneName = normalizeName(zoneName)
+	if zoneID, ok := p.zones[zoneName]; ok {
+		return zoneID
+	}
+
+	zoneID := p.newID()
+	p.zones[zoneName] = zoneID
+	p.records[zoneID] = make(map[string]provider.Record)
+	return zoneID
+}
+
+// GetZoneIDByName finds a zone ID by its name
+func (p *Provider) GetZoneIDByName(zoneName string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	zoneID, ok := p.zones[normalizeName(zoneName)]
+	if !ok {
+		return "", fmt.Errorf("error getting zone ID for %s: %w", zoneName, provider.ErrZoneNotFound)
+	}
+	return zoneID, nil
+}
+
+// FindZone finds the zone a record name belongs to by matching the longest zone name that is a suffix of the record name
+func (p *Provider) FindZone(_ context.Context, recordName string) (*provider.Zone, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	zones := make([]provider.Zone, 0, len(p.zones))
+	for name, id := range p.zones {
+		zones = append(zones, provider.Zone{ID: id, Name: name})
+	}
+	return provider.MatchZone(zones, recordName)
+}
+
+// FindRecord finds the first DNS record by zone, name, and type
+func (p *Provider) FindRecord(_ context.Context, zoneID, recordName string, recordType provider.RecordType) (*provider.Record, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	records, err := p.findRecords(zoneID, recordName, recordType)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// FindRecords finds every DNS record in a zone with the given name and type
+func (p *Provider) FindRecords(_ context.Context, zoneID, recordName string, recordType provider.RecordType) ([]provider.Record, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.findRecords(zoneID, recordName, recordType)
+}
+
+// ListRecords lists all DNS records in a zone
+func (p *Provider) ListRecords(_ context.Context, zoneID string) ([]provider.Record, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	zoneRecords, ok := p.records[zoneID]
+	if !ok {
+		return nil, fmt.Errorf("zone %s does not exist", zoneID)
+	}
+
+	records := make([]provider.Record, 0, len(zoneRecords))
+	for _, record := range zoneRecords {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// UpsertRecord creates or updates a DNS record with ownership checking
+func (p *Provider) UpsertRecord(_ context.Context, zoneID string, record provider.Record) (*provider.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing, err := p.findRecords(zoneID, record.Name, record.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Name = normalizeName(record.Name)
+	if len(existing) > 0 {
+		if provider.HasOwnerConflict(existing[0], record) {
+			return nil, provider.NewOwnershipConflictError(existing[0], record)
+		}
+		record.ID = existing[0].ID
+	} else {
+		record.ID = p.newID()
+	}
+
+	p.records[zoneID][record.ID] = record
+	return &record, nil
+}
+
+// UpsertRecordSet makes the DNS records with a name and type match the given records, with ownership checking
+func (p *Provider) UpsertRecordSet(_ context.Context, zoneID string, records []provider.Record) (provider.RecordSetChanges, error) {
+	if len(records) == 0 {
+		return provider.RecordSetChanges{}, fmt.Errorf("record set must contain at least one record")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing, err := p.findRecords(zoneID, records[0].Name, records[0].Type)
+	if err != nil {
+		return provider.RecordSetChanges{}, err
+	}
+
+	if err := provider.CheckRecordSetOwnership(existing, records[0]); err != nil {
+		return provider.RecordSetChanges{}, err
+	}
+
+	normalized := make([]provider.Record, len(records))
+	for i, record := range records {
+		record.Name = normalizeName(record.Name)
+		normalized[i] = record
+	}
+	changes := provider.DiffRecordSet(existing, normalized)
+	var made provider.RecordSetChanges
+	for _, record := range changes.Update {
+		if p.records[zoneID][record.ID] == record {
+			continue // Already up to date
+		}
+		p.records[zoneID][record.ID] = record
+		made.Update = append(made.Update, record)
+	}
+	for _, record := range changes.Create {
+		record.ID = p.newID()
+		p.records[zoneID][record.ID] = record
+		made.Create = append(made.Create, record)
+	}
+	for _, record := range changes.Delete {
+		delete(p.records[zoneID], record.ID)
+	}
+	made.Delete = changes.Delete
+
+	return made, nil
+}
+
+// DeleteRecord deletes every DNS record with the record's name and type, with ownership checking
+func (p *Provider) DeleteRecord(_ context.Context, zoneID string, record provider.Record) ([]provider.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing, err := p.findRecords(zoneID, record.Name, record.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.CheckRecordSetOwnership(existing, record); err != nil {
+		return nil, err
+	}
+
+	for _, current := range existing {
+		delete(p.records[zoneID], current.ID)
+	}
+
+	return existing, nil
+}
+
+// findRecords finds every record with a name and type in ID order, callers must hold the lock
+func (p *Provider) findRecords(zoneID, recordName string, recordType provider.RecordType) ([]provider.Record, error) {
+	zoneRecords, ok := p.records[zoneID]
+	if !ok {
+		return nil, fmt.Errorf("zone %s does not exist", zoneID)
+	}
+
+	var records []provider.Record
+	for _, record := range zoneRecords {
+		if record.Name == normalizeName(recordName) && record.Type == recordType {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+// normalizeName lowercases a DNS name and strips any trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// newID returns a new unique identifier, callers must hold the lock
+func (p *Provider) newID() string {
+	p.nextID++
+	return fmt.Sprintf("%08d", p.nextID)
+}
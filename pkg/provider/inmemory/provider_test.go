@@ -0,0 +1,53 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/starttoaster/routeflare/pkg/provider"
+)
+
+func TestGetZoneIDByName(t *testing.T) {
+	p := NewProvider()
+	zoneID := p.AddZone("example.com")
+
+	if got, err := p.GetZoneIDByName("Example.COM."); err != nil || got != zoneID {
+		t.Errorf("GetZoneIDByName = %q, %v, want %q", got, err, zoneID)
+	}
+	if _, err := p.GetZoneIDByName("example.org"); !errors.Is(err, provider.ErrZoneNotFound) {
+		t.Errorf("GetZoneIDByName error = %v, want ErrZoneNotFound", err)
+	}
+}
+
+func TestRecordNamesAreCaseInsensitive(t *testing.T) {
+	p := NewProvider()
+	zoneID := p.AddZone("example.com")
+	ctx := context.Background()
+
+	record := provider.Record{Type: provider.RecordTypeA, Name: "App.Example.com", Content: "192.0.2.1", TTL: 1, OwnerID: "routeflare"}
+	if _, err := p.UpsertRecord(ctx, zoneID, record); err != nil {
+		t.Fatalf("UpsertRecord: %v", err)
+	}
+
+	found, err := p.FindRecords(ctx, zoneID, "app.example.com.", provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("FindRecords: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != "app.example.com" {
+		t.Fatalf("got records %+v, want one named app.example.com", found)
+	}
+
+	// Publishing the set under another case updates the record rather than adding one
+	record.Name = "APP.example.com"
+	changes, err := p.UpsertRecordSet(ctx, zoneID, []provider.Record{record})
+	if err != nil {
+		t.Fatalf("UpsertRecordSet: %v", err)
+	}
+	if len(changes.Create) != 0 || len(changes.Update) != 0 || len(changes.Delete) != 0 {
+		t.Errorf("got changes %+v, want none", changes)
+	}
+	if deleted, err := p.DeleteRecord(ctx, zoneID, record); err != nil || len(deleted) != 1 {
+		t.Errorf("DeleteRecord = %+v, %v, want the record", deleted, err)
+	}
+}
@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrOwnershipConflict is returned by a Provider when a record exists but is owned by someone else
+	ErrOwnershipConflict = errors.New("record ownership conflict")
+	// ErrZoneNotFound is returned by a Provider when no accessible zone contains a record name
+	ErrZoneNotFound = errors.New("zone not found")
+)
+
+// Provider manages DNS records in a DNS backend
+type Provider interface {
+	// GetZoneIDByName finds a zone ID by its name
+	GetZoneIDByName(zoneName string) (string, error)
+	// FindZone finds the zone a record name belongs to, returning ErrZoneNotFound if no accessible zone contains it
+	FindZone(ctx context.Context, recordName string) (*Zone, error)
+	// FindRecord finds the first DNS record by zone, name, and type, returning nil if it does not exist
+	FindRecord(ctx context.Context, zoneID, recordName string, recordType RecordType) (*Record, error)
+	// FindRecords finds every DNS record in a zone with the given name and type
+	FindRecords(ctx context.Context, zoneID, recordName string, recordType RecordType) ([]Record, error)
+	// ListRecords lists all DNS records in a zone
+	ListRecords(ctx context.Context, zoneID string) ([]Record, error)
+	// UpsertRecord creates or updates a DNS record, returning ErrOwnershipConflict if it is owned by someone else
+	UpsertRecord(ctx context.Context, zoneID string, record Record) (*Record, error)
+	// UpsertRecordSet makes the records with a name and type match the given records, which must all share that name and type
+	// Missing records are created, existing ones are updated, and stale ones are deleted
+	// It returns the changes made, leaving out records that were already up to date,
+	// or ErrOwnershipConflict if any existing record in the set is owned by someone else
+	UpsertRecordSet(ctx context.Context, zoneID string, records []Record) (RecordSetChanges, error)
+	// DeleteRecord deletes every DNS record with the record's name and type, returning the deleted records,
+	// or ErrOwnershipConflict if any is owned by someone else
+	DeleteRecord(ctx context.Context, zoneID string, record Record) ([]Record, error)
+}
+
+// Zone represents a DNS zone
+type Zone struct {
+	ID   string
+	Name string
+}
+
+// MatchZone finds the zone with the longest name that is a suffix of the record name
+// The wildcard label of a wildcard record name is not matched, so "*.example.com" belongs to the zone "example.com"
+func MatchZone(zones []Zone, recordName string) (*Zone, error) {
+	recordName = strings.TrimPrefix(normalizeName(recordName), "*.")
+
+	var match *Zone
+	for i := range zones {
+		zoneName := normalizeName(zones[i].Name)
+		if recordName != zoneName && !strings.HasSuffix(recordName, "."+zoneName) {
+			continue
+		}
+		if match == nil || len(zoneName) > len(normalizeName(match.Name)) {
+			match = &zones[i]
+		}
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("%w: none of the %d accessible zones contain %s", ErrZoneNotFound, len(zones), recordName)
+	}
+	zone := *match
+	return &zone, nil
+}
+
+// IsWildcard returns true if a record name is a wildcard, such as "*.example.com"
+func IsWildcard(recordName string) bool {
+	return strings.HasPrefix(recordName, "*.")
+}
+
+// normalizeName lowercases a DNS name and strips any trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// RecordType represents a DNS record type
+type RecordType string
+
+const (
+	// RecordTypeA represents the identifier for an A record
+	RecordTypeA RecordType = "A"
+	// RecordTypeAAAA represents the identifier for an AAAA record
+	RecordTypeAAAA RecordType = "AAAA"
+	// RecordTypeCNAME represents the identifier for a CNAME record
+	RecordTypeCNAME RecordType = "CNAME"
+)
+
+// Record represents a DNS record
+type Record struct {
+	ID      string
+	Type    RecordType
+	Name    string
+	Content string
+	TTL     int // 1 = auto, or seconds
+	Proxied bool
+
+	// OwnerID is used for tracking record ownership
+	OwnerID string
+	// Source describes what the record was published for, so it can be traced back to it
+	Source Source
+}
+
+// Source describes the Kubernetes resource a DNS record was published for, and how the record's content was found
+type Source struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	UID         string
+	ContentMode string
+}
+
+// HasOwnerConflict returns true if the current record is owned by someone other than the owner of the desired record
+func HasOwnerConflict(current Record, desired Record) bool {
+	if current.OwnerID != "" && current.OwnerID != desired.OwnerID {
+		return true
+	}
+	return false
+}
+
+// CheckRecordSetOwnership returns an ownership conflict error if any of the current records is owned by someone other than the owner of the desired record
+func CheckRecordSetOwnership(current []Record, desired Record) error {
+	for _, record := range current {
+		if HasOwnerConflict(record, desired) {
+			return NewOwnershipConflictError(record, desired)
+		}
+	}
+	return nil
+}
+
+// NewOwnershipConflictError returns an error wrapping ErrOwnershipConflict that describes both owners
+func NewOwnershipConflictError(current Record, desired Record) error {
+	return fmt.Errorf("%w: existing owner '%s' does not match expected owner '%s'", ErrOwnershipConflict, current.OwnerID, desired.OwnerID)
+}
+
+// RecordSetChanges holds the changes needed to turn an existing record set into a desired one
+type RecordSetChanges struct {
+	// Create holds desired records with no existing record to reuse
+	Create []Record
+	// Update holds desired records, with the ID of the existing record they replace
+	// This includes records that are already up to date, providers may skip those
+	Update []Record
+	// Delete holds existing records that are no longer desired
+	Delete []Record
+}
+
+// DiffRecordSet works out the changes needed to turn an existing record set into a desired one
+// Existing records are matched to desired records by content, and unmatched existing records are reused for
+// unmatched desired records before anything is created or deleted, so a single record changing content is an update
+func DiffRecordSet(existing []Record, desired []Record) RecordSetChanges {
+	var changes RecordSetChanges
+
+	byContent := make(map[string]Record, len(existing))
+	for _, record := range existing {
+		if _, ok := byContent[record.Content]; !ok {
+			byContent[record.Content] = record
+		}
+	}
+
+	matched := make(map[string]bool, len(existing))
+	var unplaced []Record
+	for _, record := range desired {
+		current, ok := byContent[record.Content]
+		if !ok || matched[current.ID] {
+			unplaced = append(unplaced, record)
+			continue
+		}
+		matched[current.ID] = true
+		record.ID = current.ID
+		changes.Update = append(changes.Update, record)
+	}
+
+	var unmatched []Record
+	for _, record := range existing {
+		if !matched[record.ID] {
+			unmatched = append(unmatched, record)
+		}
+	}
+
+	for _, record := range unplaced {
+		if len(unmatched) == 0 {
+			changes.Create = append(changes.Create, record)
+			continue
+		}
+		record.ID = unmatched[0].ID
+		unmatched = unmatched[1:]
+		changes.Update = append(changes.Update, record)
+	}
+	changes.Delete = unmatched
+
+	return changes
+}
+
+// ParseTTL parses TTL string to int (1 for auto, or seconds)
+func ParseTTL(ttlStr string) (int, error) {
+	if ttlStr == "" || ttlStr == "auto" {
+		return 1, nil // Auto TTL
+	}
+
+	ttl, err := strconv.Atoi(ttlStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TTL: %s", ttlStr)
+	}
+
+	if ttl < 1 {
+		return 1, nil // Default to 1 second if TTL is invalid
+	}
+
+	return ttl, nil
+}
+
+// ParseProxied parses proxied string to bool
+func ParseProxied(proxiedStr string) (bool, error) {
+	if proxiedStr == "" {
+		return false, nil
+	}
+
+	proxied, err := strconv.ParseBool(proxiedStr)
+	if err != nil {
+		return false, fmt.Errorf("invalid proxied value: %s (must be 'true' or 'false')", proxiedStr)
+	}
+
+	return proxied, nil
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatchZone(t *testing.T) {
+	zones := []Zone{
+		{ID: "1", Name: "example.com"},
+		{ID: "2", Name: "dev.example.com"},
+		{ID: "3", Name: "example.co.uk"},
+	}
+
+	tests := []struct {
+		name       string
+		recordName string
+		want       string
+	}{
+		{name: "zone apex", recordName: "example.com", want: "1"},
+		{name: "subdomain", recordName: "app.example.com", want: "1"},
+		{name: "multi-label public suffix", recordName: "app.example.co.uk", want: "3"},
+		{name: "delegated subzone", recordName: "app.dev.example.com", want: "2"},
+		{name: "delegated subzone apex", recordName: "dev.example.com", want: "2"},
+		{name: "sibling of delegated subzone", recordName: "staging.example.com", want: "1"},
+		{name: "wildcard", recordName: "*.example.com", want: "1"},
+		{name: "wildcard in delegated subzone", recordName: "*.dev.example.com", want: "2"},
+		{name: "trailing dot", recordName: "app.example.com.", want: "1"},
+		{name: "mixed case", recordName: "App.Dev.EXAMPLE.com", want: "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone, err := MatchZone(zones, tt.recordName)
+			if err != nil {
+				t.Fatalf("MatchZone(%q): %v", tt.recordName, err)
+			}
+			if zone.ID != tt.want {
+				t.Errorf("MatchZone(%q) = %+v, want zone %s", tt.recordName, zone, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchZoneNoMatch(t *testing.T) {
+	zones := []Zone{{ID: "1", Name: "Example.com."}}
+
+	for _, recordName := range []string{"badexample.com", "app.badexample.com", "example.org", "com"} {
+		if zone, err := MatchZone(zones, recordName); !errors.Is(err, ErrZoneNotFound) {
+			t.Errorf("MatchZone(%q) = %+v, %v, want ErrZoneNotFound", recordName, zone, err)
+		}
+	}
+
+	if zone, err := MatchZone(zones, "app.example.com"); err != nil || zone.ID != "1" {
+		t.Errorf("MatchZone with a mixed case zone name = %+v, %v, want zone 1", zone, err)
+	}
+}